@@ -0,0 +1,274 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Dir returns the default location of the archive, following the XDG base
+// directory specification.
+func Dir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dir, "slag", "archive")
+}
+
+// Archive is an append-only store of every message seen, one NDJSON file of
+// components.MessageRecord per channel:
+//
+//	<dir>/<workspace>/<channel ID>.ndjson
+//
+// It implements render.Renderer so that it receives the backfill and the live
+// messages. Messages already archived are not appended again, edits and
+// deletions are appended as new records.
+type Archive struct {
+	dir   string
+	seen  map[string]map[string]bool
+	mutex sync.Mutex
+}
+
+func New(dir string) *Archive {
+	return &Archive{
+		dir:  dir,
+		seen: make(map[string]map[string]bool),
+	}
+}
+
+// Link makes the archive of the workspace, stored under its team domain,
+// available under the domain passed to slag, e.g. the name of its token in
+// the keyring, so that 'slag search DOMAIN' finds it. A previous link is
+// replaced, a directory is left alone.
+func (a *Archive) Link(domain string, workspace string) error {
+	if domain == workspace || domain == "" || workspace == "" {
+		return nil
+	}
+	link := filepath.Join(a.dir, domain)
+	info, err := os.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink == 0:
+		return fmt.Errorf("cannot link the archive of '%s' as '%s': %s exists", workspace, domain, link)
+	default:
+		if target, _ := os.Readlink(link); target == workspace {
+			return nil
+		}
+		if err = os.Remove(link); err != nil {
+			return err
+		}
+	}
+	if err = os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+	return os.Symlink(workspace, link)
+}
+
+func (a *Archive) path(workspace string, channelID string) string {
+	return filepath.Join(a.dir, workspace, channelID+".ndjson")
+}
+
+func (a *Archive) Start() error {
+	return nil
+}
+
+func (a *Archive) Message(message components.Message) error {
+	if message.Channel == nil {
+		return nil
+	}
+	return a.append(components.NewMessageRecord(message))
+}
+
+func (a *Archive) Edit(edit components.Edit) error {
+	if edit.Message.Channel == nil {
+		return nil
+	}
+	return a.append(components.NewEditRecord(edit))
+}
+
+func (a *Archive) Delete(deletion components.Deletion) error {
+	if deletion.Channel == nil {
+		return nil
+	}
+	return a.append(components.NewDeletionRecord(deletion))
+}
+
+func (a *Archive) Gap(gap components.Gap) error {
+	return nil
+}
+
+func (a *Archive) Reaction(reaction components.Reaction) error {
+	return nil
+}
+
+func (a *Archive) End() error {
+	return nil
+}
+
+func (a *Archive) append(record components.MessageRecord) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	path := a.path(record.Channel.Workspace, record.Channel.ID)
+	seen, err := a.loadSeen(path)
+	if err != nil {
+		return err
+	}
+	if record.Event == components.EventMessage && seen[record.Timestamp] {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+	seen[record.Timestamp] = true
+	return nil
+}
+
+// loadSeen returns the timestamps of the messages already in the file. The
+// mutex must be held.
+func (a *Archive) loadSeen(path string) (map[string]bool, error) {
+	if seen, ok := a.seen[path]; ok {
+		return seen, nil
+	}
+	seen := make(map[string]bool)
+	err := readRecords(path, func(record components.MessageRecord) {
+		seen[record.Timestamp] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.seen[path] = seen
+	return seen, nil
+}
+
+func readRecords(path string, fn func(components.MessageRecord)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record components.MessageRecord
+		// Skip the lines truncated by a crash
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		fn(record)
+	}
+	return scanner.Err()
+}
+
+// Query selects messages in the archive. Empty fields match everything.
+type Query struct {
+	Workspace string
+	// Text is searched, case-insensitively, in the content and attachments.
+	Text    string
+	User    string
+	Channel string
+	From    time.Time
+	To      time.Time
+}
+
+func (q Query) match(record components.MessageRecord) bool {
+	if q.User != "" && !strings.EqualFold(strings.TrimPrefix(q.User, "@"), record.User) {
+		return false
+	}
+	if q.Channel != "" && strings.TrimPrefix(q.Channel, "#") != record.Channel.Name {
+		return false
+	}
+	if !q.From.IsZero() && record.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && record.Time.After(q.To) {
+		return false
+	}
+	if q.Text == "" {
+		return true
+	}
+	text := strings.ToLower(q.Text)
+	if strings.Contains(strings.ToLower(record.Content), text) {
+		return true
+	}
+	for _, attachment := range record.Attachments {
+		if strings.Contains(strings.ToLower(attachment.Content), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the latest version of the archived messages matching the
+// query, oldest first. Deleted messages are left out.
+func (a *Archive) Search(query Query) ([]components.MessageRecord, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	workspaceDir := filepath.Join(a.dir, query.Workspace)
+	files, err := ioutil.ReadDir(workspaceDir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no archive for '%s' in %s", query.Workspace, a.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]components.MessageRecord, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".ndjson") {
+			continue
+		}
+		latest := make(map[string]components.MessageRecord)
+		order := make([]string, 0)
+		err := readRecords(filepath.Join(workspaceDir, file.Name()), func(record components.MessageRecord) {
+			if _, ok := latest[record.Timestamp]; !ok {
+				order = append(order, record.Timestamp)
+			}
+			latest[record.Timestamp] = record
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, ts := range order {
+			record := latest[ts]
+			if record.Event != components.EventDelete && query.match(record) {
+				results = append(results, record)
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.Before(results[j].Time)
+	})
+	return results, nil
+}
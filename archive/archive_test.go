@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/j-martin/slag/components"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slag-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	general := &components.Channel{ID: "C1", Name: "general", Workspace: "acme"}
+	incidents := &components.Channel{ID: "C2", Name: "incidents", Workspace: "acme"}
+	at := func(hour int) time.Time {
+		return time.Date(2026, 10, 1, hour, 0, 0, 0, time.UTC)
+	}
+	a := New(dir)
+	a.Message(components.Message{Timestamp: "1.0", Time: at(1), Channel: general, Name: "bob", Content: "hello"})
+	a.Message(components.Message{Timestamp: "2.0", Time: at(2), Channel: incidents, Name: "alice", Content: "SEV1 declared"})
+	a.Message(components.Message{Timestamp: "3.0", Time: at(3), Channel: incidents, Name: "bob", Content: "typo"})
+	a.Edit(components.Edit{Message: components.Message{Timestamp: "3.0", Time: at(3), Channel: incidents, Name: "bob", Content: "sev1 mitigated"}})
+	a.Message(components.Message{Timestamp: "4.0", Time: at(4), Channel: incidents, Name: "bob", Content: "sev1 oops"})
+	a.Delete(components.Deletion{Timestamp: "4.0", Time: at(4), Channel: incidents})
+
+	// A new instance doesn't archive the messages again
+	a = New(dir)
+	a.Message(components.Message{Timestamp: "1.0", Time: at(1), Channel: general, Name: "bob", Content: "hello"})
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"all", Query{Workspace: "acme"}, []string{"hello", "SEV1 declared", "sev1 mitigated"}},
+		{"text", Query{Workspace: "acme", Text: "sev1"}, []string{"SEV1 declared", "sev1 mitigated"}},
+		{"user", Query{Workspace: "acme", User: "@bob"}, []string{"hello", "sev1 mitigated"}},
+		{"channel", Query{Workspace: "acme", Channel: "#general"}, []string{"hello"}},
+		{"range", Query{Workspace: "acme", From: at(2), To: at(2)}, []string{"SEV1 declared"}},
+	}
+	for _, test := range tests {
+		records, err := a.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := make([]string, 0)
+		for _, record := range records {
+			contents = append(contents, record.Content)
+		}
+		if len(contents) != len(test.expected) {
+			t.Errorf("%s: %q not equal to %q", test.name, contents, test.expected)
+			continue
+		}
+		for i := range contents {
+			if contents[i] != test.expected[i] {
+				t.Errorf("%s: %q not equal to %q", test.name, contents, test.expected)
+				break
+			}
+		}
+	}
+
+	if _, err := a.Search(Query{Workspace: "other"}); err == nil {
+		t.Error("expected an error for a workspace without archive")
+	}
+
+	// The domain passed to slag may differ from the team domain.
+	for _, workspace := range []string{"other", "acme"} {
+		if err := a.Link("work", workspace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := a.Search(Query{Workspace: "work", Channel: "#general"})
+	if err != nil || len(records) != 1 {
+		t.Errorf("linked archive not found: %v, %v", records, err)
+	}
+	if err := a.Link("acme", "other"); err == nil {
+		t.Error("expected an error for an archive directory")
+	}
+}
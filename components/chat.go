@@ -1,11 +1,29 @@
 package components
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Types of channels
+const (
+	ChannelTypeChannel = "channel"
+	ChannelTypeGroup   = "group"
+	ChannelTypeMpIM    = "mpim"
+	ChannelTypeIM      = "im"
+)
+
+// ChannelTypes are all the types of channels.
+var ChannelTypes = []string{ChannelTypeChannel, ChannelTypeGroup, ChannelTypeMpIM, ChannelTypeIM}
+
 type Channel struct {
 	ID           string
+	Workspace    string
 	Name         string
 	Topic        string
 	Type         string
@@ -21,16 +39,292 @@ func (a Messages) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a Messages) Less(i, j int) bool { return a[i].Time.After(a[j].Time) }
 
 type Message struct {
+	Timestamp       string
 	ThreadTimestamp string
 	Time            time.Time
 	Channel         *Channel
+	UserID          string
 	Name            string
+	IsBot           bool
+	Mention         bool
 	Content         string
-	Attachments     []Attachment
-	IsReply         bool
+	// Body is the parsed content, nil when only the plain content is known,
+	// e.g. for the archived messages.
+	Body        []Node
+	Attachments []Attachment
+	IsReply     bool
+	Reactions   Reactions
+	// ReplyCount and LastReply describe the thread of a parent message.
+	ReplyCount int
+	LastReply  time.Time
+	// Parent is the parent message of a live reply, nil when unknown or
+	// not a reply.
+	Parent *Message
+	// Live is set for the messages received as they are posted, as opposed
+	// to the ones fetched.
+	Live bool
+}
+
+// Permalink returns the URL of the message's conversation in the web client.
+func (m Message) Permalink() string {
+	if m.Channel == nil {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.slack.com/messages/%s/convo/%s-%s/", m.Channel.Workspace, m.Channel.ID, m.Channel.ID, m.ThreadTimestamp)
+}
+
+var (
+	convoPath   = regexp.MustCompile(`^/messages/([A-Z0-9]+)/convo/([A-Z0-9]+)-([0-9]+\.[0-9]+)/?$`)
+	archivePath = regexp.MustCompile(`^/archives/([A-Z0-9]+)/p([0-9]+)([0-9]{6})$`)
+)
+
+// ParsePermalink returns the channel and the thread timestamp of a link to a
+// thread, in the format of Permalink or the one of the 'Copy link' action of
+// the Slack clients:
+//
+//	https://acme.slack.com/messages/C123/convo/C123-1538000000.000100/
+//	https://acme.slack.com/archives/C123/p1538000000000100
+//	https://acme.slack.com/archives/C123/p1538000000000200?thread_ts=1538000000.000100
+//
+// Only the ID and the workspace of the channel are set.
+func ParsePermalink(link string) (Channel, string, error) {
+	invalid := fmt.Errorf("invalid permalink: '%s'", link)
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasSuffix(u.Host, ".slack.com") {
+		return Channel{}, "", invalid
+	}
+	channel := Channel{Workspace: strings.TrimSuffix(u.Host, ".slack.com")}
+	if match := convoPath.FindStringSubmatch(u.Path); match != nil && match[1] == match[2] {
+		channel.ID = match[1]
+		return channel, match[3], nil
+	}
+	if match := archivePath.FindStringSubmatch(u.Path); match != nil {
+		channel.ID = match[1]
+		threadTimestamp := u.Query().Get("thread_ts")
+		if threadTimestamp == "" {
+			threadTimestamp = match[2] + "." + match[3]
+		}
+		return channel, threadTimestamp, nil
+	}
+	return Channel{}, "", invalid
+}
+
+// threadKey identifies the thread of a message across channels and
+// workspaces.
+func (m Message) threadKey() string {
+	if m.Channel == nil {
+		return m.ThreadTimestamp
+	}
+	return m.Channel.Workspace + "/" + m.Channel.ID + "/" + m.ThreadTimestamp
+}
+
+// Threads orders the messages oldest first, with the replies of each thread
+// right after their parent, oldest first too. The reply count and the time
+// of the last reply of the parents are set from their replies when missing.
+// The replies whose parent is not in the messages keep their place.
+func Threads(messages []Message) []Message {
+	sorted := make([]Message, len(messages))
+	copy(sorted, messages)
+	sort.Stable(sort.Reverse(Messages(sorted)))
+
+	parents := make(map[string]bool)
+	for _, message := range sorted {
+		if !message.IsReply {
+			parents[message.threadKey()] = true
+		}
+	}
+	replies := make(map[string][]Message)
+	for _, message := range sorted {
+		if message.IsReply && parents[message.threadKey()] {
+			replies[message.threadKey()] = append(replies[message.threadKey()], message)
+		}
+	}
+
+	ordered := make([]Message, 0, len(sorted))
+	for _, message := range sorted {
+		if message.IsReply {
+			if !parents[message.threadKey()] {
+				ordered = append(ordered, message)
+			}
+			continue
+		}
+		threadReplies := replies[message.threadKey()]
+		if len(threadReplies) > message.ReplyCount {
+			message.ReplyCount = len(threadReplies)
+		}
+		if len(threadReplies) > 0 && message.LastReply.IsZero() {
+			message.LastReply = threadReplies[len(threadReplies)-1].Time
+		}
+		ordered = append(ordered, message)
+		ordered = append(ordered, threadReplies...)
+	}
+	return ordered
+}
+
+// Edit is a message whose content was changed.
+type Edit struct {
+	// Message is the new version of the message.
+	Message Message
+	// Original is the message before the edit, nil when unknown.
+	Original *Message
+}
+
+// Deletion is a message that was deleted.
+type Deletion struct {
+	Channel   *Channel
+	Timestamp string
+	Time      time.Time
+	// Original is the deleted message, nil when unknown.
+	Original *Message
+}
+
+// Message returns the deleted message, as much as it is known. Its content
+// is the original content when known.
+func (d Deletion) Message() Message {
+	if d.Original != nil {
+		return *d.Original
+	}
+	return Message{
+		Timestamp:       d.Timestamp,
+		ThreadTimestamp: d.Timestamp,
+		Time:            d.Time,
+		Channel:         d.Channel,
+		Name:            "unknown",
+	}
+}
+
+// ReactionCount is the number of users who reacted to a message with an
+// emoji.
+type ReactionCount struct {
+	// Name is the Slack name of the emoji, e.g. 'thumbsup'.
+	Name string
+	// Emoji is the emoji itself, or its name between colons when unknown,
+	// e.g. custom emojis.
+	Emoji string
+	Count int
+}
+
+type Reactions []ReactionCount
+
+// String returns the reactions, e.g. '👍 3  🎉 1'.
+func (r Reactions) String() string {
+	parts := make([]string, 0, len(r))
+	for _, reaction := range r {
+		parts = append(parts, fmt.Sprintf("%s %d", reaction.Emoji, reaction.Count))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// Update returns the reactions after a user added or removed the emoji.
+func (r Reactions) Update(name string, emoji string, removed bool) Reactions {
+	updated := make(Reactions, 0, len(r)+1)
+	found := false
+	for _, reaction := range r {
+		if reaction.Name == name {
+			found = true
+			if removed {
+				reaction.Count--
+			} else {
+				reaction.Count++
+			}
+		}
+		if reaction.Count > 0 {
+			updated = append(updated, reaction)
+		}
+	}
+	if !found && !removed {
+		updated = append(updated, ReactionCount{Name: name, Emoji: emoji, Count: 1})
+	}
+	return updated
+}
+
+// Reaction is an emoji reaction added to, or removed from, a message.
+type Reaction struct {
+	Channel *Channel
+	// Timestamp is the one of the reacted message.
+	Timestamp string
+	// Time is when the reaction was added or removed.
+	Time time.Time
+	// UserID and Name are the user who reacted.
+	UserID string
+	Name   string
+	// EmojiName is the Slack name of the emoji, Emoji the emoji itself, see
+	// ReactionCount.
+	EmojiName string
+	Emoji     string
+	Removed   bool
+	// Message is the reacted message, with the updated reactions. Nil when
+	// unknown.
+	Message *Message
+}
+
+// Target returns the reacted message, as much as it is known.
+func (r Reaction) Target() Message {
+	if r.Message != nil {
+		return *r.Message
+	}
+	return Message{
+		Timestamp:       r.Timestamp,
+		ThreadTimestamp: r.Timestamp,
+		Channel:         r.Channel,
+		Name:            "unknown",
+	}
 }
 
 type Attachment struct {
 	Content string
 	Type    string
+	// Body is the content with its links parsed, nil when only the plain
+	// content is known.
+	Body []Node
+}
+
+// Gap describes the messages of a channel missed while the connection to
+// Slack was down, and fetched once it was back.
+type Gap struct {
+	Channel *Channel
+	// Since is when the connection was lost, Until when it was back.
+	Since time.Time
+	Until time.Time
+	// Count is the number of messages fetched.
+	Count int
+}
+
+func (g Gap) String() string {
+	noun := "messages"
+	if g.Count == 1 {
+		noun = "message"
+	}
+	return fmt.Sprintf("reconnected, %d %s missed since %s", g.Count, noun, g.Since.Format("15:04:05"))
+}
+
+// CompareTimestamps compares two Slack timestamps, e.g. '1538000000.000100'. An empty
+// timestamp is the oldest.
+func CompareTimestamps(a string, b string) int {
+	aSeconds, aFraction := splitTimestamp(a)
+	bSeconds, bFraction := splitTimestamp(b)
+	switch {
+	case aSeconds < bSeconds:
+		return -1
+	case aSeconds > bSeconds:
+		return 1
+	}
+	return strings.Compare(aFraction, bFraction)
+}
+
+func splitTimestamp(timestamp string) (int64, string) {
+	parts := strings.SplitN(timestamp, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return -1, ""
+	}
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+	// Pad the fraction so that the string comparison is numerical.
+	if len(fraction) < 6 {
+		fraction += strings.Repeat("0", 6-len(fraction))
+	}
+	return seconds, fraction
 }
@@ -0,0 +1,104 @@
+package components
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThreads(t *testing.T) {
+	general := &Channel{ID: "C1", Workspace: "acme"}
+	at := func(minute int) time.Time {
+		return time.Date(2026, 10, 1, 12, minute, 0, 0, time.UTC)
+	}
+	message := func(minute int, ts string, threadTs string) Message {
+		return Message{
+			Timestamp:       ts,
+			ThreadTimestamp: threadTs,
+			Time:            at(minute),
+			Channel:         general,
+			Content:         ts,
+			IsReply:         ts != threadTs,
+		}
+	}
+	messages := []Message{
+		message(5, "5", "1"),
+		message(2, "2", "2"),
+		message(3, "3", "1"),
+		message(1, "1", "1"),
+		message(4, "4", "9"),
+	}
+	threads := Threads(messages)
+	contents := make([]string, 0)
+	for _, message := range threads {
+		contents = append(contents, message.Content)
+	}
+	expected := []string{"1", "3", "5", "2", "4"}
+	if len(contents) != len(expected) {
+		t.Fatalf("%q not equal to %q", contents, expected)
+	}
+	for i := range contents {
+		if contents[i] != expected[i] {
+			t.Fatalf("%q not equal to %q", contents, expected)
+		}
+	}
+	if threads[0].ReplyCount != 2 || !threads[0].LastReply.Equal(at(5)) {
+		t.Errorf("unexpected thread summary: %d replies, last %s", threads[0].ReplyCount, threads[0].LastReply)
+	}
+	if threads[3].ReplyCount != 0 {
+		t.Errorf("unexpected replies: %d", threads[3].ReplyCount)
+	}
+}
+
+func TestParsePermalink(t *testing.T) {
+	message := Message{
+		ThreadTimestamp: "1538000000.000100",
+		Channel:         &Channel{ID: "C123", Workspace: "acme"},
+	}
+	tests := []struct {
+		link            string
+		threadTimestamp string
+		valid           bool
+	}{
+		{message.Permalink(), "1538000000.000100", true},
+		{"https://acme.slack.com/archives/C123/p1538000000000100", "1538000000.000100", true},
+		{"https://acme.slack.com/archives/C123/p1538000000000200?thread_ts=1538000000.000100&cid=C123", "1538000000.000100", true},
+		{"https://acme.slack.com/messages/C123/convo/C456-1538000000.000100/", "", false},
+		{"https://example.com/archives/C123/p1538000000000100", "", false},
+		{"C123-1538000000.000100", "", false},
+	}
+	for _, test := range tests {
+		channel, threadTimestamp, err := ParsePermalink(test.link)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.link)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.link, err)
+			continue
+		}
+		if channel.ID != "C123" || channel.Workspace != "acme" || threadTimestamp != test.threadTimestamp {
+			t.Errorf("%s: unexpected %s/%s %s", test.link, channel.Workspace, channel.ID, threadTimestamp)
+		}
+	}
+}
+
+func TestCompareTimestamps(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1538000000.000100", "1538000000.000100", 0},
+		{"1538000000.000100", "1538000000.000200", -1},
+		{"1538000001.000000", "1538000000.999999", 1},
+		{"999999999.000000", "1538000000.000000", -1},
+		{"1538000000.1", "1538000000.000200", 1},
+		{"", "1538000000.000000", -1},
+	}
+	for _, test := range tests {
+		if result := CompareTimestamps(test.a, test.b); result != test.expected {
+			t.Errorf("CompareTimestamps('%s', '%s'): expected %d, got %d", test.a, test.b, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package components
+
+import (
+	"time"
+)
+
+// MessageSchemaVersion is the version of the JSON representation of a
+// message. It must be incremented whenever a field is removed or its meaning
+// changes; adding new fields is backward compatible and does not require a
+// bump.
+//
+// Version 2 added the Event field: besides the messages, the records describe
+// the edits, the deletions, the reactions added or removed and the gaps
+// after a reconnection, see the Event constants. The readers of version 1
+// must skip the records whose event is not 'message'. Content is also the
+// plain text of the parsed mrkdwn instead of the mrkdwn source: without the
+// style markers, with the entities decoded, and with the links written as
+// 'label (url)'.
+const MessageSchemaVersion = 2
+
+// Kinds of events a MessageRecord can describe, its 'event' field.
+const (
+	EventMessage = "message"
+	EventEdit    = "edit"
+	EventDelete  = "delete"
+	EventGap     = "gap"
+
+	EventReactionAdded   = "reaction_added"
+	EventReactionRemoved = "reaction_removed"
+)
+
+// MessageRecord is the stable, machine-readable representation of a Message
+// used by the JSON output mode. One record is emitted per line.
+type MessageRecord struct {
+	Version         int                `json:"version"`
+	Event           string             `json:"event"`
+	Timestamp       string             `json:"ts"`
+	Time            time.Time          `json:"time"`
+	Channel         ChannelRecord      `json:"channel"`
+	User            string             `json:"user"`
+	Content         string             `json:"content"`
+	Attachments     []AttachmentRecord `json:"attachments"`
+	ThreadTimestamp string             `json:"thread_ts"`
+	IsReply         bool               `json:"is_reply"`
+	Mention         bool               `json:"mention"`
+	Permalink       string             `json:"permalink"`
+	// ReplyCount is the number of replies in the thread of a parent message.
+	ReplyCount int `json:"reply_count,omitempty"`
+	// PreviousContent is the content before an edit or a deletion, only set
+	// for these events when known.
+	PreviousContent string `json:"previous_content,omitempty"`
+	// Reactions are the reaction counts of the message.
+	Reactions []ReactionRecord `json:"reactions,omitempty"`
+	// Reaction is the emoji added or removed, only set for the reaction
+	// events. Its count is the one after the event, when known.
+	Reaction *ReactionRecord `json:"reaction,omitempty"`
+	// Missed is the number of messages fetched after a gap, only set for
+	// the gap events.
+	Missed int `json:"missed,omitempty"`
+}
+
+type ChannelRecord struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Workspace string `json:"workspace"`
+}
+
+type ReactionRecord struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+type AttachmentRecord struct {
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// NewMessageRecord converts a Message to its JSON representation.
+func NewMessageRecord(message Message) MessageRecord {
+	attachments := make([]AttachmentRecord, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		attachments = append(attachments, AttachmentRecord{
+			Type:    attachment.Type,
+			Content: attachment.Content,
+		})
+	}
+	var reactions []ReactionRecord
+	for _, reaction := range message.Reactions {
+		reactions = append(reactions, ReactionRecord{
+			Name:  reaction.Name,
+			Emoji: reaction.Emoji,
+			Count: reaction.Count,
+		})
+	}
+	record := MessageRecord{
+		Version:         MessageSchemaVersion,
+		Event:           EventMessage,
+		Timestamp:       message.Timestamp,
+		Time:            message.Time.UTC(),
+		User:            message.Name,
+		Content:         message.Content,
+		Attachments:     attachments,
+		ThreadTimestamp: message.ThreadTimestamp,
+		IsReply:         message.IsReply,
+		Mention:         message.Mention,
+		Permalink:       message.Permalink(),
+		Reactions:       reactions,
+		ReplyCount:      message.ReplyCount,
+	}
+	if message.Channel != nil {
+		record.Channel = ChannelRecord{
+			ID:        message.Channel.ID,
+			Name:      message.Channel.Name,
+			Workspace: message.Channel.Workspace,
+		}
+	}
+	return record
+}
+
+// NewEditRecord converts an Edit to its JSON representation, the record
+// holds the new version of the message.
+func NewEditRecord(edit Edit) MessageRecord {
+	record := NewMessageRecord(edit.Message)
+	record.Event = EventEdit
+	if edit.Original != nil {
+		record.PreviousContent = edit.Original.Content
+	}
+	return record
+}
+
+// NewDeletionRecord converts a Deletion to its JSON representation, the
+// record has no content.
+func NewDeletionRecord(deletion Deletion) MessageRecord {
+	message := deletion.Message()
+	record := NewMessageRecord(message)
+	record.Event = EventDelete
+	record.Content = ""
+	record.PreviousContent = message.Content
+	record.Attachments = []AttachmentRecord{}
+	return record
+}
+
+// NewReactionRecord converts a Reaction to its JSON representation. The
+// record has the timestamp and the permalink of the reacted message, but the
+// user and the time of the reaction.
+func NewReactionRecord(reaction Reaction) MessageRecord {
+	target := reaction.Target()
+	record := NewMessageRecord(target)
+	record.Event = EventReactionAdded
+	if reaction.Removed {
+		record.Event = EventReactionRemoved
+	}
+	record.Time = reaction.Time.UTC()
+	record.User = reaction.Name
+	record.Content = ""
+	record.Attachments = []AttachmentRecord{}
+	record.Reactions = nil
+	record.Reaction = &ReactionRecord{Name: reaction.EmojiName, Emoji: reaction.Emoji}
+	for _, count := range target.Reactions {
+		if count.Name == reaction.EmojiName {
+			record.Reaction.Count = count.Count
+		}
+	}
+	return record
+}
+
+// NewGapRecord converts a Gap to its JSON representation. The time is the
+// start of the gap.
+func NewGapRecord(gap Gap) MessageRecord {
+	record := MessageRecord{
+		Version:     MessageSchemaVersion,
+		Event:       EventGap,
+		Time:        gap.Since.UTC(),
+		Content:     gap.String(),
+		Attachments: []AttachmentRecord{},
+		Missed:      gap.Count,
+	}
+	if gap.Channel != nil {
+		record.Channel = ChannelRecord{
+			ID:        gap.Channel.ID,
+			Name:      gap.Channel.Name,
+			Workspace: gap.Channel.Workspace,
+		}
+	}
+	return record
+}
+
+// Message converts the record back to a Message, e.g. when reading an
+// archive.
+func (r MessageRecord) Message() Message {
+	attachments := make([]Attachment, 0, len(r.Attachments))
+	for _, attachment := range r.Attachments {
+		attachments = append(attachments, Attachment{
+			Type:    attachment.Type,
+			Content: attachment.Content,
+		})
+	}
+	var reactions Reactions
+	for _, reaction := range r.Reactions {
+		reactions = append(reactions, ReactionCount{
+			Name:  reaction.Name,
+			Emoji: reaction.Emoji,
+			Count: reaction.Count,
+		})
+	}
+	return Message{
+		Timestamp:       r.Timestamp,
+		ThreadTimestamp: r.ThreadTimestamp,
+		Time:            r.Time.Local(),
+		Channel: &Channel{
+			ID:        r.Channel.ID,
+			Name:      r.Channel.Name,
+			Workspace: r.Channel.Workspace,
+		},
+		Name:        r.User,
+		Content:     r.Content,
+		Attachments: attachments,
+		IsReply:     r.IsReply,
+		Mention:     r.Mention,
+		Reactions:   reactions,
+		ReplyCount:  r.ReplyCount,
+	}
+}
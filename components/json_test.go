@@ -0,0 +1,73 @@
+package components
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewMessageRecord(t *testing.T) {
+	message := Message{
+		Timestamp:       "1538000000.000100",
+		ThreadTimestamp: "1538000000.000100",
+		Time:            time.Unix(1538000000, 0),
+		Channel:         &Channel{ID: "C123", Name: "general", Workspace: "acme"},
+		Name:            "bob",
+		Content:         "hello",
+		Attachments:     []Attachment{{Content: "a title", Type: "title"}},
+	}
+	data, err := json.Marshal(NewMessageRecord(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":2,"event":"message","ts":"1538000000.000100","time":"2018-09-26T22:13:20Z",` +
+		`"channel":{"id":"C123","name":"general","workspace":"acme"},"user":"bob","content":"hello",` +
+		`"attachments":[{"type":"title","content":"a title"}],"thread_ts":"1538000000.000100",` +
+		`"is_reply":false,"mention":false,"permalink":"https://acme.slack.com/messages/C123/convo/C123-1538000000.000100/"}`
+	if string(data) != expected {
+		t.Errorf("'%s' not equal to '%s'", data, expected)
+	}
+}
+
+func TestNewEditAndDeletionRecords(t *testing.T) {
+	original := Message{
+		Timestamp: "1538000000.000100",
+		Channel:   &Channel{ID: "C123", Name: "general", Workspace: "acme"},
+		Name:      "bob",
+		Content:   "helo",
+	}
+	edited := original
+	edited.Content = "hello"
+
+	record := NewEditRecord(Edit{Message: edited, Original: &original})
+	if record.Event != EventEdit || record.Content != "hello" || record.PreviousContent != "helo" {
+		t.Errorf("unexpected edit record: %+v", record)
+	}
+	record = NewEditRecord(Edit{Message: edited})
+	if record.PreviousContent != "" {
+		t.Errorf("unexpected previous content: '%s'", record.PreviousContent)
+	}
+
+	record = NewDeletionRecord(Deletion{Channel: original.Channel, Timestamp: original.Timestamp, Original: &edited})
+	if record.Event != EventDelete || record.Content != "" || record.PreviousContent != "hello" || record.User != "bob" {
+		t.Errorf("unexpected deletion record: %+v", record)
+	}
+	record = NewDeletionRecord(Deletion{Channel: original.Channel, Timestamp: original.Timestamp})
+	if record.User != "unknown" || record.Timestamp != original.Timestamp || record.PreviousContent != "" {
+		t.Errorf("unexpected deletion record: %+v", record)
+	}
+}
+
+func TestReactionsUpdate(t *testing.T) {
+	reactions := Reactions{{Name: "+1", Emoji: "👍", Count: 1}}
+	reactions = reactions.Update("tada", "🎉", false)
+	reactions = reactions.Update("+1", "👍", false)
+	if reactions.String() != "👍 2  🎉 1" {
+		t.Errorf("unexpected reactions: '%s'", reactions)
+	}
+	reactions = reactions.Update("tada", "🎉", true)
+	reactions = reactions.Update("eyes", "👀", true)
+	if reactions.String() != "👍 2" {
+		t.Errorf("unexpected reactions: '%s'", reactions)
+	}
+}
@@ -0,0 +1,80 @@
+package components
+
+import (
+	"strings"
+)
+
+// NodeType is the kind of a Node.
+type NodeType int
+
+// Kinds of nodes of a mrkdwn document.
+//
+// https://api.slack.com/reference/surfaces/formatting#basics
+const (
+	// NodeText is plain text, the entities already decoded.
+	NodeText NodeType = iota
+	// NodeBold, NodeItalic and NodeStrike style their children, e.g.
+	// '*bold*', '_italic_' and '~strike~'.
+	NodeBold
+	NodeItalic
+	NodeStrike
+	// NodeCode is inline code, e.g. '`code`'.
+	NodeCode
+	// NodeCodeBlock is a preformatted block, e.g. '```code```'.
+	NodeCodeBlock
+	// NodeQuote is a block quote, its children can span multiple lines.
+	NodeQuote
+	// NodeListItem is an item of a bulleted or numbered list.
+	NodeListItem
+	// NodeLink is a link, e.g. '<https://example.com|example>', its
+	// children are the label.
+	NodeLink
+)
+
+// Node is an element of a parsed mrkdwn message. The blocks (code blocks,
+// quotes and list items) span whole lines, the newlines separating them from
+// the rest are text nodes.
+type Node struct {
+	Type NodeType
+	// Text is the content of the text and code nodes, the marker of the
+	// list items, e.g. '•' or '1.', and the URL of the links.
+	Text     string
+	Children []Node
+}
+
+// PlainText returns the text of the nodes without styles. The quoted lines
+// are prefixed with '> ', the list items with their marker, and the links are
+// written as 'label (url)'.
+func PlainText(nodes []Node) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case NodeText, NodeCode, NodeCodeBlock:
+			b.WriteString(node.Text)
+		case NodeQuote:
+			b.WriteString("> " + strings.Replace(PlainText(node.Children), "\n", "\n> ", -1))
+		case NodeListItem:
+			b.WriteString(node.Text + " " + PlainText(node.Children))
+		case NodeLink:
+			b.WriteString(LinkText(node.Text, PlainText(node.Children)))
+		default:
+			b.WriteString(PlainText(node.Children))
+		}
+	}
+	return b.String()
+}
+
+// LinkText returns the text of a link for the displays without hyperlinks,
+// 'label (url)', or only one of them when the label is empty or repeats the
+// URL, e.g. 'example.com' for 'https://example.com'.
+func LinkText(url string, label string) string {
+	if label == "" {
+		return url
+	}
+	for _, scheme := range []string{"", "https://", "http://", "mailto:"} {
+		if scheme+label == url {
+			return label
+		}
+	}
+	return label + " (" + url + ")"
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml"
+)
+
+// DefaultProfile is the name of the profile applied to every invocation,
+// before the selected profile.
+const DefaultProfile = "default"
+
+// Config is the content of the configuration file, e.g.:
+//
+//	[profiles.default]
+//	count = 20
+//
+//	[profiles.oncall]
+//	domains = ["acme"]
+//	include = "incident|oncall"
+//	exclude = ["-test$"]
+//	channels = ["incidents", "@erroneousboat"]
+//	format = "compact"
+//	timezone = "America/Montreal"
+//	highlight = ["sev1", "sev2"]
+//	bell = true
+//	mark = "[!]"
+//	channel_colors = { incidents = "bright-red" }
+//	notify = true
+//	notify_muted = ["^random$"]
+//	muted_users = ["deploybot"]
+//	content = ["(?i)incident|sev[12]"]
+//	bots = "none"
+type Config struct {
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+// Profile holds the settings of a named profile. Unset values fall back to
+// the default profile, then to the command line defaults.
+type Profile struct {
+	Domains     []string `toml:"domains"`
+	Include     string   `toml:"include"`
+	Exclude     []string `toml:"exclude"`
+	Channels    []string `toml:"channels"`
+	Count       *int     `toml:"count"`
+	Format      string   `toml:"format"`
+	Threads     *bool    `toml:"threads"`
+	Hyperlinks  *bool    `toml:"hyperlinks"`
+	Timezone    string   `toml:"timezone"`
+	Highlight   []string `toml:"highlight"`
+	Bell        *bool    `toml:"bell"`
+	Mark        string   `toml:"mark"`
+	Notify      *bool    `toml:"notify"`
+	NotifyMuted []string `toml:"notify_muted"`
+	Archive     *bool    `toml:"archive"`
+	Resume      *bool    `toml:"resume"`
+	Transport   string   `toml:"transport"`
+	PageSize    *int     `toml:"page_size"`
+	Concurrency *int     `toml:"concurrency"`
+	MutedUsers  []string `toml:"muted_users"`
+
+	// UserColors and ChannelColors map user and channel names to colors,
+	// e.g. 'red', '196' or '#ff0000'.
+	UserColors    map[string]string `toml:"user_colors"`
+	ChannelColors map[string]string `toml:"channel_colors"`
+
+	// Message filters, see filter.Filter
+	Users              []string `toml:"users"`
+	Content            []string `toml:"content"`
+	ExcludeContent     []string `toml:"exclude_content"`
+	Attachments        []string `toml:"attachments"`
+	ExcludeAttachments []string `toml:"exclude_attachments"`
+	Bots               string   `toml:"bots"`
+	Replies            string   `toml:"replies"`
+	ChannelTypes       []string `toml:"channel_types"`
+}
+
+// Path returns the location of the configuration file, following the XDG
+// base directory specification.
+func Path() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "slag", "config.toml")
+}
+
+// Load reads the configuration file at path. A missing file yields an empty
+// configuration.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a TOML configuration.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	err := toml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err)
+	}
+	return config, nil
+}
+
+// Profile returns the default profile overlaid with the named one. When name
+// is empty, the profile named after the domain is used if there is one.
+func (c *Config) Profile(name string, domain string) (Profile, error) {
+	profile := c.Profiles[DefaultProfile]
+	if name == "" {
+		name = domain
+		if _, ok := c.Profiles[name]; !ok {
+			return profile, nil
+		}
+	}
+	named, ok := c.Profiles[name]
+	if !ok {
+		return profile, fmt.Errorf("unknown profile: '%s'", name)
+	}
+	return profile.merge(named), nil
+}
+
+func (p Profile) merge(other Profile) Profile {
+	if len(other.Domains) > 0 {
+		p.Domains = other.Domains
+	}
+	if other.Include != "" {
+		p.Include = other.Include
+	}
+	if len(other.Exclude) > 0 {
+		p.Exclude = other.Exclude
+	}
+	if len(other.Channels) > 0 {
+		p.Channels = other.Channels
+	}
+	if other.Count != nil {
+		p.Count = other.Count
+	}
+	if other.Format != "" {
+		p.Format = other.Format
+	}
+	if other.Threads != nil {
+		p.Threads = other.Threads
+	}
+	if other.Hyperlinks != nil {
+		p.Hyperlinks = other.Hyperlinks
+	}
+	if len(other.UserColors) > 0 {
+		p.UserColors = mergeColors(p.UserColors, other.UserColors)
+	}
+	if len(other.ChannelColors) > 0 {
+		p.ChannelColors = mergeColors(p.ChannelColors, other.ChannelColors)
+	}
+	if other.Timezone != "" {
+		p.Timezone = other.Timezone
+	}
+	if len(other.Highlight) > 0 {
+		p.Highlight = other.Highlight
+	}
+	if other.Bell != nil {
+		p.Bell = other.Bell
+	}
+	if other.Mark != "" {
+		p.Mark = other.Mark
+	}
+	if other.Notify != nil {
+		p.Notify = other.Notify
+	}
+	if len(other.NotifyMuted) > 0 {
+		p.NotifyMuted = other.NotifyMuted
+	}
+	if other.Archive != nil {
+		p.Archive = other.Archive
+	}
+	if other.Resume != nil {
+		p.Resume = other.Resume
+	}
+	if other.Transport != "" {
+		p.Transport = other.Transport
+	}
+	if other.PageSize != nil {
+		p.PageSize = other.PageSize
+	}
+	if other.Concurrency != nil {
+		p.Concurrency = other.Concurrency
+	}
+	if len(other.MutedUsers) > 0 {
+		p.MutedUsers = other.MutedUsers
+	}
+	if len(other.Users) > 0 {
+		p.Users = other.Users
+	}
+	if len(other.Content) > 0 {
+		p.Content = other.Content
+	}
+	if len(other.ExcludeContent) > 0 {
+		p.ExcludeContent = other.ExcludeContent
+	}
+	if len(other.Attachments) > 0 {
+		p.Attachments = other.Attachments
+	}
+	if len(other.ExcludeAttachments) > 0 {
+		p.ExcludeAttachments = other.ExcludeAttachments
+	}
+	if other.Bots != "" {
+		p.Bots = other.Bots
+	}
+	if other.Replies != "" {
+		p.Replies = other.Replies
+	}
+	if len(other.ChannelTypes) > 0 {
+		p.ChannelTypes = other.ChannelTypes
+	}
+	return p
+}
+
+// mergeColors returns the colors overlaid with the other ones, unlike the
+// lists which are replaced.
+func mergeColors(colors map[string]string, other map[string]string) map[string]string {
+	merged := make(map[string]string, len(colors)+len(other))
+	for name, color := range colors {
+		merged[name] = color
+	}
+	for name, color := range other {
+		merged[name] = color
+	}
+	return merged
+}
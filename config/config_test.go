@@ -0,0 +1,80 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testConfig = `
+[profiles.default]
+count = 20
+format = "verbose"
+muted_users = ["deploybot"]
+channel_colors = { general = "green", incidents = "red" }
+
+[profiles.acme]
+include = "^dev-"
+
+[profiles.oncall]
+domains = ["acme"]
+include = "incident|oncall"
+exclude = ["-test$"]
+count = 50
+timezone = "America/Montreal"
+highlight = ["sev1", "sev2"]
+bots = "none"
+channel_types = ["channel", "group"]
+channel_colors = { incidents = "#ff0000" }
+`
+
+func TestProfile(t *testing.T) {
+	config, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := config.Profile("oncall", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 50
+	expected := Profile{
+		Domains:      []string{"acme"},
+		Include:      "incident|oncall",
+		Exclude:      []string{"-test$"},
+		Count:        &count,
+		Format:       "verbose",
+		Timezone:     "America/Montreal",
+		Highlight:    []string{"sev1", "sev2"},
+		MutedUsers:   []string{"deploybot"},
+		Bots:         "none",
+		ChannelTypes: []string{"channel", "group"},
+		ChannelColors: map[string]string{
+			"general":   "green",
+			"incidents": "#ff0000",
+		},
+	}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("%+v not equal to %+v", profile, expected)
+	}
+
+	profile, err = config.Profile("", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Include != "^dev-" || *profile.Count != 20 {
+		t.Errorf("domain profile not applied: %+v", profile)
+	}
+
+	profile, err = config.Profile("", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Include != "" || *profile.Count != 20 {
+		t.Errorf("default profile not applied: %+v", profile)
+	}
+
+	if _, err = config.Profile("missing", "acme"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/j-martin/slag/components"
+)
+
+// ChannelSelector decides which channels are watched.
+type ChannelSelector struct {
+	// Include only selects the channels matching the regex.
+	Include *regexp.Regexp
+	// Exclude drops the channels matching one of the regexes.
+	Exclude []*regexp.Regexp
+	// Names only selects these channels, e.g. 'general', '#general' or
+	// '@erroneousboat' for direct messages.
+	Names []string
+	// Types only selects these types of channels.
+	Types []string
+}
+
+// CheckChannelTypes returns an error when one of the types is not a type of
+// channel, e.g. a typo that would select no channel.
+func CheckChannelTypes(types []string) error {
+	for _, t := range types {
+		if !contains(components.ChannelTypes, t) {
+			last := len(components.ChannelTypes) - 1
+			return fmt.Errorf("invalid channel type: '%s', expected '%s' or '%s'", t,
+				strings.Join(components.ChannelTypes[:last], "', '"), components.ChannelTypes[last])
+		}
+	}
+	return nil
+}
+
+// Select returns the selected channels, in order.
+func (s *ChannelSelector) Select(channels []components.Channel) []components.Channel {
+	selected := make([]components.Channel, 0)
+	for _, channel := range channels {
+		if s.match(channel) {
+			selected = append(selected, channel)
+		}
+	}
+	return selected
+}
+
+func (s *ChannelSelector) match(channel components.Channel) bool {
+	if s.Include != nil && !s.Include.MatchString(channel.Name) {
+		return false
+	}
+	if matchAny(s.Exclude, channel.Name) {
+		return false
+	}
+	if len(s.Types) > 0 && !contains(s.Types, channel.Type) {
+		return false
+	}
+	if len(s.Names) > 0 && !s.matchName(channel) {
+		return false
+	}
+	return true
+}
+
+func (s *ChannelSelector) matchName(channel components.Channel) bool {
+	for _, name := range s.Names {
+		if nameMatches(name, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+func nameMatches(name string, channel components.Channel) bool {
+	switch {
+	case strings.HasPrefix(name, "@"):
+		return channel.Type == components.ChannelTypeIM && channel.Name == name[1:]
+	case strings.HasPrefix(name, "#"):
+		return channel.Type != components.ChannelTypeIM && channel.Name == name[1:]
+	default:
+		return channel.Name == name
+	}
+}
+
+// Unmatched returns the names that don't designate any of the channels.
+func (s *ChannelSelector) Unmatched(channels []components.Channel) []string {
+	unmatched := make([]string, 0)
+	for _, name := range s.Names {
+		found := false
+		for _, channel := range channels {
+			if nameMatches(name, channel) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, name)
+		}
+	}
+	return unmatched
+}
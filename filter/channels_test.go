@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+)
+
+func TestChannelSelector(t *testing.T) {
+	channels := []components.Channel{
+		{ID: "C1", Name: "general", Type: components.ChannelTypeChannel},
+		{ID: "C2", Name: "random", Type: components.ChannelTypeChannel},
+		{ID: "C3", Name: "dev-alerts", Type: components.ChannelTypeChannel},
+		{ID: "G1", Name: "mpdm-alice--bob-1", Type: components.ChannelTypeMpIM},
+		{ID: "D1", Name: "alice", Type: components.ChannelTypeIM},
+	}
+
+	tests := []struct {
+		name      string
+		selector  ChannelSelector
+		expected  []string
+		unmatched []string
+	}{
+		{"all", ChannelSelector{}, []string{"C1", "C2", "C3", "G1", "D1"}, []string{}},
+		{"include", ChannelSelector{Include: regexp.MustCompile("^dev-")}, []string{"C3"}, []string{}},
+		{
+			"exclude",
+			ChannelSelector{Exclude: []*regexp.Regexp{regexp.MustCompile("random"), regexp.MustCompile("^mpdm-")}},
+			[]string{"C1", "C3", "D1"},
+			[]string{},
+		},
+		{"names", ChannelSelector{Names: []string{"#general", "@alice", "ops"}}, []string{"C1", "D1"}, []string{"ops"}},
+		{"im names", ChannelSelector{Names: []string{"@general", "#alice"}}, []string{}, []string{"@general", "#alice"}},
+		{"types", ChannelSelector{Types: []string{"im", "mpim"}}, []string{"G1", "D1"}, []string{}},
+	}
+	for _, test := range tests {
+		ids := make([]string, 0)
+		for _, channel := range test.selector.Select(channels) {
+			ids = append(ids, channel.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s: %v not equal to %v", test.name, ids, test.expected)
+		}
+		if unmatched := test.selector.Unmatched(channels); !reflect.DeepEqual(unmatched, test.unmatched) {
+			t.Errorf("%s: unmatched %v not equal to %v", test.name, unmatched, test.unmatched)
+		}
+	}
+}
+
+func TestCheckChannelTypes(t *testing.T) {
+	if err := CheckChannelTypes([]string{"channel", "im"}); err != nil {
+		t.Error(err)
+	}
+	err := CheckChannelTypes([]string{"privte"})
+	expected := "invalid channel type: 'privte', expected 'channel', 'group', 'mpim' or 'im'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("%v not equal to %s", err, expected)
+	}
+}
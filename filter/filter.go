@@ -0,0 +1,191 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+// Selection tells whether a kind of message is displayed.
+type Selection string
+
+const (
+	All  Selection = "all"
+	Only Selection = "only"
+	None Selection = "none"
+)
+
+// ParseSelection parses 'all', 'only' or 'none'. An empty string is 'all'.
+func ParseSelection(s string) (Selection, error) {
+	switch Selection(s) {
+	case "", All:
+		return All, nil
+	case Only, None:
+		return Selection(s), nil
+	default:
+		return All, fmt.Errorf("invalid selection: '%s', expected 'all', 'only' or 'none'", s)
+	}
+}
+
+func (s Selection) match(value bool) bool {
+	switch s {
+	case Only:
+		return value
+	case None:
+		return !value
+	default:
+		return true
+	}
+}
+
+// Filter decides which messages are passed to the renderer. Empty lists
+// match everything.
+type Filter struct {
+	// Users only displays the messages of these users.
+	Users []string
+	// MutedUsers hides the messages of these users.
+	MutedUsers []string
+	// Content only displays the messages matching one of the regexes.
+	Content []*regexp.Regexp
+	// ExcludeContent hides the messages matching one of the regexes.
+	ExcludeContent []*regexp.Regexp
+	// Attachments only displays the messages with an attachment matching one
+	// of the regexes.
+	Attachments []*regexp.Regexp
+	// ExcludeAttachments hides the messages with an attachment matching one
+	// of the regexes.
+	ExcludeAttachments []*regexp.Regexp
+	// Bots selects the messages posted by bots.
+	Bots Selection
+	// Replies selects the replies in threads.
+	Replies Selection
+	// ChannelTypes only displays the messages posted in these types of
+	// channels, see components.ChannelTypeChannel and friends.
+	ChannelTypes []string
+	// Thread only displays the parent and the replies of the thread with
+	// this timestamp.
+	Thread string
+}
+
+// Match returns true when the message should be displayed.
+func (f *Filter) Match(message components.Message) bool {
+	if contains(f.MutedUsers, message.Name) {
+		return false
+	}
+	if len(f.Users) > 0 && !contains(f.Users, message.Name) {
+		return false
+	}
+	if f.Thread != "" && message.ThreadTimestamp != f.Thread {
+		return false
+	}
+	if !f.Bots.match(message.IsBot) || !f.Replies.match(message.IsReply) {
+		return false
+	}
+	if len(f.ChannelTypes) > 0 && message.Channel != nil && !contains(f.ChannelTypes, message.Channel.Type) {
+		return false
+	}
+	if len(f.Content) > 0 && !matchAny(f.Content, message.Content) {
+		return false
+	}
+	if matchAny(f.ExcludeContent, message.Content) {
+		return false
+	}
+	if len(f.Attachments) > 0 && !matchAttachments(f.Attachments, message.Attachments) {
+		return false
+	}
+	if matchAttachments(f.ExcludeAttachments, message.Attachments) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(regexes []*regexp.Regexp, s string) bool {
+	for _, r := range regexes {
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAttachments(regexes []*regexp.Regexp, attachments []components.Attachment) bool {
+	for _, attachment := range attachments {
+		if matchAny(regexes, attachment.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileAll compiles the regexes.
+func CompileAll(exprs []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, r)
+	}
+	return regexes, nil
+}
+
+type filteredRenderer struct {
+	render.Renderer
+	filter *Filter
+}
+
+// Renderer wraps r so that only the messages matched by f are rendered.
+func Renderer(r render.Renderer, f *Filter) render.Renderer {
+	return &filteredRenderer{Renderer: r, filter: f}
+}
+
+func (r *filteredRenderer) Message(message components.Message) error {
+	if !r.filter.Match(message) {
+		return nil
+	}
+	return r.Renderer.Message(message)
+}
+
+func (r *filteredRenderer) Edit(edit components.Edit) error {
+	if !r.filter.Match(edit.Message) {
+		return nil
+	}
+	return r.Renderer.Edit(edit)
+}
+
+// Reaction matches the reacted message.
+func (r *filteredRenderer) Reaction(reaction components.Reaction) error {
+	if !r.filter.Match(r.inThread(reaction.Target(), reaction.Message != nil)) {
+		return nil
+	}
+	return r.Renderer.Reaction(reaction)
+}
+
+func (r *filteredRenderer) Delete(deletion components.Deletion) error {
+	if !r.filter.Match(r.inThread(deletion.Message(), deletion.Original != nil)) {
+		return nil
+	}
+	return r.Renderer.Delete(deletion)
+}
+
+// inThread returns the message of a reaction or a deletion. The thread of an
+// unknown message is unknown as well, it is assumed to be the thread
+// followed, if any, so that the events on the replies not seen are kept.
+func (r *filteredRenderer) inThread(message components.Message, known bool) components.Message {
+	if !known && r.filter.Thread != "" {
+		message.ThreadTimestamp = r.filter.Thread
+	}
+	return message
+}
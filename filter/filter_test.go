@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+func TestMatch(t *testing.T) {
+	channel := &components.Channel{ID: "C123", Name: "ops", Type: components.ChannelTypeChannel}
+	deploy := components.Message{
+		Channel:         channel,
+		ThreadTimestamp: "1538000000.000100",
+		Name:            "deploybot",
+		IsBot:           true,
+		Content:         "Deployed api to production",
+		Attachments:     []components.Attachment{{Content: "SEV2 rollback", Type: "text"}},
+	}
+	reply := components.Message{
+		Channel:         channel,
+		ThreadTimestamp: "1538000000.000100",
+		Name:            "bob",
+		Content:         "incident is resolved",
+		IsReply:         true,
+	}
+	im := components.Message{
+		Channel: &components.Channel{ID: "D123", Name: "alice", Type: components.ChannelTypeIM},
+		Name:    "alice",
+		Content: "hi",
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []bool
+	}{
+		{"empty", Filter{}, []bool{true, true, true}},
+		{"users", Filter{Users: []string{"bob", "alice"}}, []bool{false, true, true}},
+		{"muted users", Filter{MutedUsers: []string{"deploybot"}}, []bool{false, true, true}},
+		{"only bots", Filter{Bots: Only}, []bool{true, false, false}},
+		{"no bots", Filter{Bots: None}, []bool{false, true, true}},
+		{"only replies", Filter{Replies: Only}, []bool{false, true, false}},
+		{"no replies", Filter{Replies: None}, []bool{true, false, true}},
+		{"thread", Filter{Thread: "1538000000.000100"}, []bool{true, true, false}},
+		{"channel types", Filter{ChannelTypes: []string{"im", "mpim"}}, []bool{false, false, true}},
+		{"content", Filter{Content: []*regexp.Regexp{regexp.MustCompile(`(?i)incident|sev[12]`)}}, []bool{false, true, false}},
+		{"exclude content", Filter{ExcludeContent: []*regexp.Regexp{regexp.MustCompile(`^hi$`)}}, []bool{true, true, false}},
+		{"attachments", Filter{Attachments: []*regexp.Regexp{regexp.MustCompile(`(?i)sev[12]`)}}, []bool{true, false, false}},
+		{"exclude attachments", Filter{ExcludeAttachments: []*regexp.Regexp{regexp.MustCompile(`rollback`)}}, []bool{false, true, true}},
+	}
+	for _, test := range tests {
+		for i, message := range []components.Message{deploy, reply, im} {
+			if matched := test.filter.Match(message); matched != test.expected[i] {
+				t.Errorf("%s: message %d: expected %v, got %v", test.name, i, test.expected[i], matched)
+			}
+		}
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	for input, expected := range map[string]Selection{"": All, "all": All, "only": Only, "none": None} {
+		selection, err := ParseSelection(input)
+		if err != nil || selection != expected {
+			t.Errorf("'%s': expected %s, got %s (%v)", input, expected, selection, err)
+		}
+	}
+	if _, err := ParseSelection("some"); err == nil {
+		t.Error("expected an error for an invalid selection")
+	}
+}
+
+// counter counts the deletions and reactions rendered.
+type counter struct {
+	render.Renderer
+	deletions int
+	reactions int
+}
+
+func (c *counter) Delete(deletion components.Deletion) error {
+	c.deletions++
+	return nil
+}
+
+func (c *counter) Reaction(reaction components.Reaction) error {
+	c.reactions++
+	return nil
+}
+
+func TestRendererThread(t *testing.T) {
+	channel := &components.Channel{ID: "C123", Name: "ops"}
+	other := &components.Message{Channel: channel, Timestamp: "9.0", ThreadTimestamp: "9.0"}
+	c := &counter{}
+	r := Renderer(c, &Filter{Thread: "1.0"})
+	// The replies not seen are unknown, their events are kept.
+	r.Delete(components.Deletion{Channel: channel, Timestamp: "2.0"})
+	r.Reaction(components.Reaction{Channel: channel, Timestamp: "2.0"})
+	r.Delete(components.Deletion{Channel: channel, Timestamp: "9.0", Original: other})
+	r.Reaction(components.Reaction{Channel: channel, Timestamp: "9.0", Message: other})
+	if c.deletions != 1 || c.reactions != 1 {
+		t.Errorf("expected 1 deletion and 1 reaction, got %d and %d", c.deletions, c.reactions)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+
+	"github.com/j-martin/slag/filter"
+)
+
+// stringList is a flag that can be repeated and holds comma separated
+// values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// regexList is a flag that can be repeated. Unlike stringList, values are not
+// split on commas since they are common in regexes.
+type regexList []string
+
+func (l *regexList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *regexList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+var (
+	flagExcludeChannels    regexList
+	flagChannels           stringList
+	flagUsers              stringList
+	flagMutedUsers         stringList
+	flagContent            regexList
+	flagExcludeContent     regexList
+	flagAttachments        regexList
+	flagExcludeAttachments regexList
+	flagBots               string
+	flagReplies            string
+	flagChannelTypes       stringList
+)
+
+func init() {
+	flag.Var(&flagExcludeChannels, "x", "Regex to exclude channels.")
+	flag.Var(&flagChannels, "c", "Channels to watch.")
+	flag.Var(&flagUsers, "u", "Only display the messages of these users.")
+	flag.Var(&flagMutedUsers, "xu", "Hide the messages of these users.")
+	flag.Var(&flagContent, "m", "Only display the messages matching the regex.")
+	flag.Var(&flagExcludeContent, "xm", "Hide the messages matching the regex.")
+	flag.Var(&flagAttachments, "a", "Only display the messages with an attachment matching the regex.")
+	flag.Var(&flagExcludeAttachments, "xa", "Hide the messages with an attachment matching the regex.")
+	flag.StringVar(&flagBots, "bots", "", "Messages posted by bots: all, only or none.")
+	flag.StringVar(&flagReplies, "replies", "", "Replies in threads: all, only or none.")
+	flag.Var(&flagChannelTypes, "t", "Only watch these channel types: channel, group, mpim or im.")
+}
+
+// applyFilterProfile sets the filter options not passed on the command line
+// from the profile.
+func applyFilterProfile(passed map[string]bool) {
+	if !passed["x"] {
+		flagExcludeChannels = profile.Exclude
+	}
+	if !passed["c"] {
+		flagChannels = profile.Channels
+	}
+	if !passed["u"] {
+		flagUsers = profile.Users
+	}
+	if !passed["xu"] {
+		flagMutedUsers = profile.MutedUsers
+	}
+	if !passed["m"] {
+		flagContent = profile.Content
+	}
+	if !passed["xm"] {
+		flagExcludeContent = profile.ExcludeContent
+	}
+	if !passed["a"] {
+		flagAttachments = profile.Attachments
+	}
+	if !passed["xa"] {
+		flagExcludeAttachments = profile.ExcludeAttachments
+	}
+	if !passed["bots"] {
+		flagBots = profile.Bots
+	}
+	if !passed["replies"] {
+		flagReplies = profile.Replies
+	}
+	if !passed["t"] {
+		flagChannelTypes = profile.ChannelTypes
+	}
+}
+
+// newChannelSelector creates the channel selector from the options.
+func newChannelSelector() (*filter.ChannelSelector, error) {
+	err := filter.CheckChannelTypes(flagChannelTypes)
+	if err != nil {
+		return nil, err
+	}
+	s := &filter.ChannelSelector{
+		Names: flagChannels,
+		Types: flagChannelTypes,
+	}
+	if s.Include, err = regexp.Compile(flagRegexFilter); err != nil {
+		return nil, err
+	}
+	if s.Exclude, err = filter.CompileAll(flagExcludeChannels); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newFilter creates the message filter from the options.
+func newFilter() (*filter.Filter, error) {
+	err := filter.CheckChannelTypes(flagChannelTypes)
+	if err != nil {
+		return nil, err
+	}
+	f := &filter.Filter{
+		Users:        flagUsers,
+		MutedUsers:   flagMutedUsers,
+		ChannelTypes: flagChannelTypes,
+	}
+	if f.Content, err = filter.CompileAll(flagContent); err != nil {
+		return nil, err
+	}
+	if f.ExcludeContent, err = filter.CompileAll(flagExcludeContent); err != nil {
+		return nil, err
+	}
+	if f.Attachments, err = filter.CompileAll(flagAttachments); err != nil {
+		return nil, err
+	}
+	if f.ExcludeAttachments, err = filter.CompileAll(flagExcludeAttachments); err != nil {
+		return nil, err
+	}
+	if f.Bots, err = filter.ParseSelection(flagBots); err != nil {
+		return nil, err
+	}
+	if f.Replies, err = filter.ParseSelection(flagReplies); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
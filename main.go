@@ -3,15 +3,22 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/fatih/color"
+	"github.com/j-martin/slag/archive"
 	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/config"
+	"github.com/j-martin/slag/filter"
+	"github.com/j-martin/slag/notify"
+	"github.com/j-martin/slag/render"
 	"github.com/j-martin/slag/secrets"
 	"github.com/j-martin/slag/service"
-	"github.com/nlopes/slack"
+	"github.com/j-martin/slag/state"
+	"github.com/j-martin/slag/tui"
+	"github.com/mattn/go-isatty"
 	"log"
-	"regexp"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,7 +28,9 @@ const (
 		slag - slack channel aggregator for your terminal
 
 USAGE:
-		slag DOMAIN
+		slag [OPTIONS] DOMAIN [DOMAIN...]
+		slag [OPTIONS] -profile PROFILE
+		slag [OPTIONS] COMMAND [COMMAND OPTIONS] ARGUMENTS...
 
 VERSION:
 		%s
@@ -30,20 +39,154 @@ WEBSITE:
 		https://github.com/j-martin/slag
 
 ARGUMENTS
-	 DOMAIN   Domain/workspace to use. 
+	 DOMAIN   Domain/workspace to use. Optional when the profile defines it.
+	          When multiple domains are passed, their messages are merged
+	          in a single stream.
+
+COMMANDS:
+	 post      Post a message to a channel, see 'slag post -h'.
+	 search    Search the local archive, see 'slag search -h'.
+	 tail      Follow a single channel, see 'slag tail -h'.
+	 thread    Follow a single thread, see 'slag thread -h'.
 
 GLOBAL OPTIONS:
 	 -f [REGEX]        Regex to filter channels. Default: '.*'
-	 -n [INT]          Number of previous message to display per channel.
-	 -reset-token      Reset the API token for the domain.
+	 -x [REGEX]        Regex to exclude channels. Can be repeated.
+	 -c [CHANNELS]     Comma separated list of channels to watch, e.g.
+	                   'general,#dev,@erroneousboat'. Can be repeated.
+	 -n [INT]          Number of previous message to display per channel, when
+	                   slag has never seen the channel or -resume=false.
+	 -resume           Display every message posted since the last message seen
+	                   in each channel. Default: true
+	 -since [TIME]     Display every message posted since the time, e.g. '2h'
+	                   or '2026-10-01'. Overrides -resume and -n.
+	 -until [TIME]     Only display the messages posted before the time, e.g.
+	                   '30m' or '2026-10-02'.
+	 -page-size [INT]  Number of messages per history request. Default: %d
+	 -concurrency [INT]
+	                   Number of channels fetched at once. Default: %d
+	 -state [PATH]     File of the last message seen. Default: '%s'
+	 -o [FORMAT]       Output format: %s. Default: 'verbose'
+	 -tui              Interactive mode with a channel sidebar and an input line.
+	 -threads          Group the replies under their parent, and show the parent
+	                   of the new replies.
+	 -hyperlinks       Show the links as clickable labels, with OSC 8 terminal
+	                   hyperlinks. Default: true when stdout is a terminal.
+	 -k [WORDS]        Comma separated keywords to highlight.
+	 -bell             Ring the terminal bell on mentions and keywords.
+	 -mark [STRING]    Prefix the messages with mentions or keywords.
+	 -notify           Desktop notifications for direct messages, mentions and
+	                   keywords (D-Bus).
+	 -notify-mute [REGEX]
+	                   Regex of the channels without notifications. Can be repeated.
+	 -archive          Archive every message seen, for 'slag search'. Default: true
+	 -archive-dir [PATH]
+	                   Archive directory. Default: '%s'
+	 -transport [NAME] How to receive the new messages: 'rtm', the legacy Real
+	                   Time Messaging API, or 'socket', Socket Mode. Default: 'rtm'
+	 -reset-token      Reset the API tokens for the domains.
+	 -config [PATH]    Configuration file. Default: '%s'
+	 -profile [NAME]   Profile to load from the configuration file.
+	                   Default: the profile named after the domain, if any.
 	 -help, -h
+
+MESSAGE FILTERS:
+	 Lists are comma separated, and the options can be repeated.
+
+	 -u [USERS]        Only display the messages of these users.
+	 -xu [USERS]       Hide the messages of these users.
+	 -m [REGEX]        Only display the messages matching the regex.
+	 -xm [REGEX]       Hide the messages matching the regex.
+	 -a [REGEX]        Only display the messages with an attachment matching the regex.
+	 -xa [REGEX]       Hide the messages with an attachment matching the regex.
+	 -bots [SELECTION] Messages posted by bots: 'all', 'only' or 'none'.
+	 -replies [SEL.]   Replies in threads: 'all', 'only' or 'none'.
+	 -t [TYPES]        Only watch these channel types: 'channel', 'group',
+	                   'mpim' or 'im'.
+
+COLORS:
+	 Every user and channel gets a color derived from its ID, from the 256
+	 colors or the 24-bit colors when the terminal supports them (TERM or
+	 COLORTERM). The colors are disabled when stdout is not a terminal or
+	 NO_COLOR is set. The profiles can assign colors to some users and
+	 channels: a name, e.g. 'red' or 'bright-red', an index of the 256-color
+	 palette, e.g. '196', or an RGB color, e.g. '#ff0000'.
+
+TRANSPORTS:
+	 rtm      Requires a legacy token or the user token of a classic Slack
+	          app, see https://api.slack.com/custom-integrations/legacy-tokens
+	          The Block Kit content of the new messages, posted by most bots,
+	          is not received, only the one of the messages fetched.
+	 socket   Requires a Slack app with Socket Mode enabled, subscribed to the
+	          message and reaction events (e.g. 'message.channels',
+	          'message.im', 'reaction_added', 'reaction_removed'). Both its
+	          user token (xoxp-...) and an app-level token (xapp-...) with the
+	          'connections:write' scope are prompted for.
+
+CONFIGURATION:
+	 Profiles are defined in a TOML file. The 'default' profile applies to
+	 every invocation, command line options override profile settings.
+
+		[profiles.default]
+		count = 20
+
+		[profiles.oncall]
+		domains = ["acme"]
+		include = "incident|oncall"
+		exclude = ["-test$"]
+		channels = ["incidents", "@erroneousboat"]
+		format = "compact"
+		threads = true
+		hyperlinks = false
+		user_colors = { erroneousboat = "#ff8700" }
+		channel_colors = { incidents = "bright-red", general = "245" }
+		timezone = "America/Montreal"
+		highlight = ["sev1", "sev2"]
+		bell = true
+		mark = "[!]"
+		notify = true
+		notify_muted = ["^random$"]
+		archive = true
+		resume = true
+		transport = "socket"
+		page_size = 100
+		concurrency = 2
+		muted_users = ["deploybot"]
+		content = ["(?i)incident|sev[12]"]
+		bots = "none"
 `
+
+	// defaultConcurrency is the number of channels fetched at once.
+	defaultConcurrency = 4
 )
 
 var (
 	flagRegexFilter       string
 	flagResetToken        bool
+	flagTransport         string
 	flagMessageFetchCount int
+	flagOutputFormat      string
+	flagConfigPath        string
+	flagProfile           string
+	flagTUI               bool
+	flagThreads           bool
+	flagHyperlinks        bool
+	flagKeywords          stringList
+	flagBell              bool
+	flagMark              string
+	flagNotify            bool
+	flagNotifyMuted       regexList
+	flagArchive           bool
+	flagArchiveDir        string
+	flagResume            bool
+	flagSince             string
+	flagStatePath         string
+	flagUntil             string
+	flagPageSize          int
+	flagConcurrency       int
+
+	domains []string
+	profile config.Profile
 )
 
 func init() {
@@ -62,113 +205,629 @@ func init() {
 		"Number of historical messages to fetch, per channels.",
 	)
 
+	flag.StringVar(
+		&flagOutputFormat,
+		"o",
+		"verbose",
+		"Output format: compact, verbose, markdown or json.",
+	)
+
+	flag.BoolVar(
+		&flagTUI,
+		"tui",
+		false,
+		"Interactive mode.",
+	)
+
+	flag.BoolVar(
+		&flagThreads,
+		"threads",
+		false,
+		"Group the replies under their parent.",
+	)
+
+	flag.BoolVar(
+		&flagHyperlinks,
+		"hyperlinks",
+		isatty.IsTerminal(os.Stdout.Fd()) && os.Getenv("TERM") != "dumb",
+		"Show the links as clickable labels.",
+	)
+
+	flag.Var(
+		&flagKeywords,
+		"k",
+		"Keywords to highlight.",
+	)
+
+	flag.BoolVar(
+		&flagBell,
+		"bell",
+		false,
+		"Ring the terminal bell on mentions and keywords.",
+	)
+
+	flag.StringVar(
+		&flagMark,
+		"mark",
+		"",
+		"Prefix of the messages with mentions or keywords.",
+	)
+
+	flag.BoolVar(
+		&flagNotify,
+		"notify",
+		false,
+		"Desktop notifications for direct messages, mentions and keywords.",
+	)
+
+	flag.Var(
+		&flagNotifyMuted,
+		"notify-mute",
+		"Regex of the channels without notifications.",
+	)
+
+	flag.BoolVar(
+		&flagResume,
+		"resume",
+		true,
+		"Display every message posted since the last message seen.",
+	)
+
+	flag.StringVar(
+		&flagSince,
+		"since",
+		"",
+		"Display every message posted since the time, e.g. 2h or 2026-10-01.",
+	)
+
+	flag.StringVar(
+		&flagUntil,
+		"until",
+		"",
+		"Only display the messages posted before the time, e.g. 30m or 2026-10-02.",
+	)
+
+	flag.IntVar(
+		&flagPageSize,
+		"page-size",
+		service.DefaultPageSize,
+		"Number of messages per history request.",
+	)
+
+	flag.IntVar(
+		&flagConcurrency,
+		"concurrency",
+		defaultConcurrency,
+		"Number of channels fetched at once.",
+	)
+
+	flag.StringVar(
+		&flagStatePath,
+		"state",
+		state.Path(),
+		"File of the last message seen.",
+	)
+
+	flag.BoolVar(
+		&flagArchive,
+		"archive",
+		true,
+		"Archive every message seen.",
+	)
+
+	flag.StringVar(
+		&flagArchiveDir,
+		"archive-dir",
+		archive.Dir(),
+		"Archive directory.",
+	)
+
+	flag.StringVar(
+		&flagTransport,
+		"transport",
+		"rtm",
+		"How to receive the new messages: rtm or socket.",
+	)
+
 	flag.BoolVar(
 		&flagResetToken,
 		"reset-token",
 		false,
 		"Reset the API token for the domain.",
 	)
+
+	flag.StringVar(
+		&flagConfigPath,
+		"config",
+		config.Path(),
+		"Configuration file.",
+	)
+
+	flag.StringVar(
+		&flagProfile,
+		"profile",
+		"",
+		"Profile to load from the configuration file.",
+	)
 	flag.Usage = func() {
-		fmt.Printf(USAGE, VERSION)
+		fmt.Printf(USAGE, VERSION, service.DefaultPageSize, defaultConcurrency, state.Path(), strings.Join(render.Names(), ", "), archive.Dir(), config.Path())
 	}
+}
 
+func main() {
 	flag.Parse()
-	if flag.Arg(0) == "" || len(flag.Args()) != 1 {
+	switch flag.Arg(0) {
+	case "post":
+		post(flag.Args()[1:])
+	case "search":
+		search(flag.Args()[1:])
+	case "tail":
+		tail(flag.Args()[1:])
+	case "thread":
+		thread(flag.Args()[1:])
+	default:
+		loadSettings()
+		stream()
+	}
+}
+
+// loadSettings resolves the domains and the profile of the stream.
+func loadSettings() {
+	domains = flag.Args()
+	loadProfile(flag.Arg(0))
+
+	if len(domains) == 0 {
+		domains = profile.Domains
+	}
+	if len(domains) == 0 {
 		flag.Usage()
 		log.Fatal("The domain must be passed as an argument.")
 	}
 }
 
-func main() {
-	var apiToken string
-	err := secrets.New("slack").LoadCredentialItem(
-		flag.Arg(0),
-		&apiToken,
-		"Generate the api token at: https://api.slack.com/custom-integrations/legacy-tokens",
-		flagResetToken)
+// loadProfile loads the profile selected by -profile, or the one named after
+// the domain, and applies it.
+func loadProfile(domain string) {
+	cfg, err := config.Load(flagConfigPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	svc, err := service.NewSlackService(apiToken)
+	profile, err = cfg.Profile(flagProfile, domain)
 	if err != nil {
 		log.Fatal(err)
 	}
-	channels, err := svc.GetChannels()
-	messagesCh := make(chan []components.Message)
-	r, _ := regexp.Compile(flagRegexFilter)
-	watchedChannels := make(map[string]*components.Channel)
-	watchedChannelNames := make([]string, 0)
-	for _, channel := range channels {
-		if !r.MatchString(channel.Name) {
-			continue
+	applyProfile(profile)
+}
+
+// applyProfile sets the options not passed on the command line from the
+// profile.
+func applyProfile(profile config.Profile) {
+	passed := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+	if !passed["f"] && profile.Include != "" {
+		flagRegexFilter = profile.Include
+	}
+	if !passed["n"] && profile.Count != nil {
+		flagMessageFetchCount = *profile.Count
+	}
+	if !passed["o"] && profile.Format != "" {
+		flagOutputFormat = profile.Format
+	}
+	if !passed["threads"] && profile.Threads != nil {
+		flagThreads = *profile.Threads
+	}
+	if !passed["hyperlinks"] && profile.Hyperlinks != nil {
+		flagHyperlinks = *profile.Hyperlinks
+	}
+	if !passed["k"] {
+		flagKeywords = profile.Highlight
+	}
+	if !passed["bell"] && profile.Bell != nil {
+		flagBell = *profile.Bell
+	}
+	if !passed["mark"] && profile.Mark != "" {
+		flagMark = profile.Mark
+	}
+	if !passed["notify"] && profile.Notify != nil {
+		flagNotify = *profile.Notify
+	}
+	if !passed["notify-mute"] {
+		flagNotifyMuted = profile.NotifyMuted
+	}
+	if !passed["archive"] && profile.Archive != nil {
+		flagArchive = *profile.Archive
+	}
+	if !passed["resume"] && profile.Resume != nil {
+		flagResume = *profile.Resume
+	}
+	if !passed["transport"] && profile.Transport != "" {
+		flagTransport = profile.Transport
+	}
+	if !passed["page-size"] && profile.PageSize != nil {
+		flagPageSize = *profile.PageSize
+	}
+	if !passed["concurrency"] && profile.Concurrency != nil {
+		flagConcurrency = *profile.Concurrency
+	}
+	applyFilterProfile(passed)
+	if profile.Timezone != "" {
+		location, err := time.LoadLocation(profile.Timezone)
+		if err != nil {
+			log.Fatal(err)
 		}
-		ch := channel
-		watchedChannels[channel.ID] = &ch
-		watchedChannelNames = append(watchedChannelNames, ch.Name)
-		if flagMessageFetchCount == 0 {
-			continue
+		time.Local = location
+	}
+}
+
+// renderOptions returns the options of the renderers, from the command line
+// and the profile.
+func renderOptions() render.Options {
+	return render.Options{
+		Highlight:     flagKeywords,
+		Bell:          flagBell,
+		Mark:          flagMark,
+		Mentions:      make(map[string]string),
+		Threaded:      flagThreads,
+		Hyperlinks:    flagHyperlinks,
+		Palette:       render.DetectPalette(),
+		UserColors:    parseColors(profile.UserColors, "@"),
+		ChannelColors: parseColors(profile.ChannelColors, "#"),
+	}
+}
+
+// parseColors parses the colors of a profile, by user or channel name. The
+// names may start with the prefix, e.g. '#incidents'.
+func parseColors(colors map[string]string, prefix string) map[string]render.Color {
+	parsed := make(map[string]render.Color, len(colors))
+	for name, spec := range colors {
+		c, err := render.ParseColor(spec)
+		if err != nil {
+			log.Fatalf("%s%s: %s", prefix, strings.TrimPrefix(name, prefix), err)
 		}
-		go func() {
-			channelMessages, err := svc.GetMessages(ch, flagMessageFetchCount)
-			if err != nil {
-				log.Fatal(err)
-			}
-			messagesCh <- channelMessages
-		}()
+		parsed[strings.TrimPrefix(name, prefix)] = c
+	}
+	return parsed
+}
+
+// workspace holds the connection to a domain and the channels watched in it.
+type workspace struct {
+	domain   string
+	svc      *service.SlackService
+	channels map[string]*components.Channel
+	ordered  []components.Channel
+	names    []string
+}
+
+// stream prints the latest messages of the watched channels, then the new
+// ones as they arrive.
+func stream() {
+	options := renderOptions()
+	options.ShowWorkspace = len(domains) > 1
+	selector, err := newChannelSelector()
+	if err != nil {
+		log.Fatal(err)
 	}
-	messages := make([]components.Message, 0)
-	if flagMessageFetchCount != 0 {
-		log.Printf("Fetching: %s ...", strings.Join(watchedChannelNames, ", "))
 
-		for i := 0; i < len(watchedChannels); i++ {
-			messages = append(messages, <-messagesCh...)
+	workspaces := make([]*workspace, 0, len(domains))
+	allChannels := make([]components.Channel, 0)
+	for _, domain := range domains {
+		ws, channels, err := connect(domain, selector)
+		if err != nil {
+			log.Fatalf("%s: %s", domain, err)
 		}
+		workspaces = append(workspaces, ws)
+		allChannels = append(allChannels, channels...)
+	}
+	if unmatched := selector.Unmatched(allChannels); len(unmatched) > 0 {
+		log.Printf("No conversation matched: %s", strings.Join(unmatched, ", "))
 	}
 
-	close(messagesCh)
+	watchedChannelNames := make([]string, 0)
+	for _, ws := range workspaces {
+		watchedChannelNames = append(watchedChannelNames, ws.names...)
+		options.Mentions[ws.svc.CurrentTeamInfo.Domain] = "@" + ws.svc.CurrentUsername
+		ws.svc.Threaded = options.Threaded
+	}
+	if len(watchedChannelNames) == 0 {
+		log.Fatal("No channels matched the channel filters.")
+	}
 
-	if len(watchedChannels) == 0 {
-		log.Fatalf("No channels matched the regex filter: '%s'", flagRegexFilter)
+	var ui *tui.UI
+	var renderer render.Renderer
+	if flagTUI {
+		ui = newUI(workspaces, options)
+		renderer = ui
+	} else {
+		renderer, err = render.New(flagOutputFormat, os.Stdout, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	messageFilter, err := newFilter()
+	if err != nil {
+		log.Fatal(err)
 	}
+	renderer = render.Synchronized(filter.Renderer(renderer, messageFilter))
 
-	sort.Sort(sort.Reverse(components.Messages(messages)))
+	// Every message is archived, regardless of the filters.
+	if flagArchive {
+		messageArchive := archive.New(flagArchiveDir)
+		for _, ws := range workspaces {
+			if err := messageArchive.Link(ws.domain, ws.svc.CurrentTeamInfo.Domain); err != nil {
+				log.Printf("%s: %s", ws.domain, err)
+			}
+		}
+		renderer = render.Multi(renderer, messageArchive)
+	}
 
+	// The last message seen is tracked regardless of the filters as well,
+	// otherwise the hidden messages would be fetched again on every start.
+	lastSeen, err := state.Load(flagStatePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	renderer = render.Multi(renderer, lastSeen)
+	if !flagResume {
+		lastSeen = nil
+	}
+	query := service.HistoryQuery{
+		Count:    flagMessageFetchCount,
+		PageSize: flagPageSize,
+	}
+	if flagSince != "" {
+		start, err := parseSince(flagSince)
+		if err != nil {
+			log.Fatal(err)
+		}
+		query.Oldest = service.Timestamp(start)
+	}
+	if flagUntil != "" {
+		end, err := parseSince(flagUntil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		query.Latest = service.Timestamp(end)
+	}
+
+	// Only the live messages trigger notifications, not the backfill.
+	live := renderer
+	if flagNotify {
+		notifier, err := newNotifier(options, workspaces)
+		if err != nil {
+			log.Fatal(err)
+		}
+		live = render.Multi(renderer, filter.Renderer(notifier, messageFilter))
+	}
+
+	log.Printf("Fetching: %s ...", strings.Join(watchedChannelNames, ", "))
+	messages := backfill(workspaces, query, lastSeen, flagConcurrency)
+
+	if flagThreads {
+		messages = components.Threads(messages)
+	} else {
+		sort.Sort(sort.Reverse(components.Messages(messages)))
+	}
+
+	err = renderer.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ui != nil {
+		log.SetOutput(ui)
+	}
 	for _, message := range messages {
-		printMessage(message, svc.CurrentTeamInfo)
+		err = renderer.Message(message)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-	if flagMessageFetchCount == 0 {
+	if len(messages) == 0 {
 		log.Printf("Listening to %s for new messages ...", strings.Join(watchedChannelNames, ", "))
 	}
-	err = svc.ListenToEvents(watchedChannels, printMessage)
+
+	// Fan in the events of every workspace, the first error stops slag.
+	errs := make(chan error, len(workspaces)+1)
+	for _, ws := range workspaces {
+		go func(ws *workspace) {
+			err := ws.svc.ListenToEvents(ws.channels, live)
+			if err != nil {
+				err = fmt.Errorf("%s: %s", ws.domain, err)
+			}
+			errs <- err
+		}(ws)
+	}
+	if ui != nil {
+		go func() {
+			errs <- ui.Run()
+		}()
+	}
+	err = <-errs
+	if endErr := renderer.End(); endErr != nil && err == nil {
+		err = endErr
+	}
+	log.SetOutput(os.Stderr)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func printMessage(message components.Message, teamInfo *slack.TeamInfo) {
-	threadSymbol := ""
-	if message.IsReply {
-		threadSymbol = "≡"
+// newUI creates the interactive interface listing the watched channels of
+// every workspace. Messages typed in it are posted with the service of the
+// channel's workspace.
+func newUI(workspaces []*workspace, options render.Options) *tui.UI {
+	channels := make([]components.Channel, 0)
+	services := make(map[string]*service.SlackService)
+	for _, ws := range workspaces {
+		channels = append(channels, ws.ordered...)
+		services[ws.svc.CurrentTeamInfo.Domain] = ws.svc
 	}
-	fmt.Println(
-		color.MagentaString("%s [%s]", message.Time.UTC().Format(time.RFC3339), message.Time.Format("15:04:05Z07:00")),
-		color.New().Add(color.Faint).Sprintf("https://%s.slack.com/messages/%s/convo/%s-%s/", teamInfo.Domain, message.Channel.ID, message.Channel.ID, message.ThreadTimestamp),
-		threadSymbol,
-	)
-	fmt.Printf("%s %s ",
-		color.CyanString("[#%s]", message.Channel.Name),
-		color.RedString("@%s", message.Name),
-	)
-	if len(message.Content) > 0 {
-		fmt.Println(message.Content)
+	send := func(channel components.Channel, text string) error {
+		_, err := services[channel.Workspace].PostMessage(channel.ID, text, "")
+		return err
+	}
+	return tui.New(channels, send, options)
+}
+
+// newNotifier creates the desktop notifier, ignoring the messages of the
+// current user.
+func newNotifier(options render.Options, workspaces []*workspace) (*notify.Notifier, error) {
+	sender, err := notify.SessionBusSender()
+	if err != nil {
+		return nil, fmt.Errorf("desktop notifications are not available: %s", err)
+	}
+	rules := notify.DefaultRules
+	rules.MutedChannels, err = filter.CompileAll(flagNotifyMuted)
+	if err != nil {
+		return nil, err
+	}
+	for _, ws := range workspaces {
+		rules.IgnoredUsers = append(rules.IgnoredUsers, ws.svc.CurrentUsername)
+	}
+	return notify.New(sender, options, rules), nil
+}
+
+// connect creates the service for the domain, with its own token, and
+// selects the channels to watch. All the channels of the workspace are
+// returned as well.
+func connect(domain string, selector *filter.ChannelSelector) (*workspace, []components.Channel, error) {
+	svc, err := newService(domain, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	channels, err := svc.GetChannels()
+	if err != nil {
+		return nil, nil, err
+	}
+	ws := &workspace{
+		domain:   domain,
+		svc:      svc,
+		channels: make(map[string]*components.Channel),
+		names:    make([]string, 0),
+	}
+	for _, channel := range selector.Select(channels) {
+		ch := channel
+		ws.channels[channel.ID] = &ch
+		ws.ordered = append(ws.ordered, ch)
+		name := ch.Name
+		if len(domains) > 1 {
+			name = domain + "/" + name
+		}
+		ws.names = append(ws.names, name)
 	}
-	for _, attachment := range message.Attachments {
-		if attachment.Type == "text" {
-			println(attachment.Content)
-		} else {
-			color.New().Add(color.Faint).Println(attachment.Content)
+	return ws, channels, nil
+}
+
+// newService loads the tokens of the domain and creates its service. It
+// listens to the events with the transport selected, unless it only calls the
+// Web API, e.g. to post.
+func newService(domain string, listen bool) (*service.SlackService, error) {
+	var apiToken, appToken string
+	description := "Generate the api token at: https://api.slack.com/custom-integrations/legacy-tokens"
+	switch flagTransport {
+	case "rtm":
+	case "socket":
+		description = "Paste the user token (xoxp-...) of your Slack app, see: https://api.slack.com/apps"
+	default:
+		return nil, fmt.Errorf("unknown transport: '%s', expected 'rtm' or 'socket'", flagTransport)
+	}
+	secretService := secrets.New("slack")
+	err := secretService.LoadCredentialItem(domain, &apiToken, description, flagResetToken)
+	if err != nil {
+		return nil, err
+	}
+	if !listen {
+		return service.NewSlackClient(apiToken)
+	}
+	if flagTransport == "socket" {
+		err = secretService.LoadCredentialItem(
+			domain+"/app-token",
+			&appToken,
+			"Paste the app-level token (xapp-...) of your Slack app, with the connections:write scope, see: https://api.slack.com/apps",
+			flagResetToken)
+		if err != nil {
+			return nil, err
 		}
+	}
+	svc, err := service.NewSlackService(apiToken, appToken)
+	if err != nil {
+		return nil, err
+	}
+	svc.Concurrency = flagConcurrency
+	return svc, nil
+}
 
+// backfill fetches the messages of every watched channel, in every workspace,
+// with at most concurrency channels fetched at once. The query applies to
+// every channel, when it has no oldest bound the messages posted since the
+// last message seen are fetched, if the state is set and knows the channel.
+// The count only applies when there is no oldest bound. The channels that
+// could not be fetched are logged and skipped.
+func backfill(workspaces []*workspace, query service.HistoryQuery, lastSeen *state.LastSeen, concurrency int) []components.Message {
+	type job struct {
+		svc     *service.SlackService
+		channel components.Channel
+		query   service.HistoryQuery
+	}
+	jobs := make([]job, 0)
+	for _, ws := range workspaces {
+		for _, channel := range ws.channels {
+			channelQuery := query
+			if channelQuery.Oldest == "" && lastSeen != nil {
+				channelQuery.Oldest, _ = lastSeen.Get(channel)
+			}
+			if channelQuery.Oldest != "" {
+				channelQuery.Count = 0
+			} else if channelQuery.Count == 0 {
+				continue
+			}
+			jobs = append(jobs, job{ws.svc, *channel, channelQuery})
+		}
+	}
+
+	messages := make([]components.Message, 0)
+	errs := make([]string, 0)
+	progress := newProgress(len(jobs))
+	var mutex sync.Mutex
+	service.ForEach(len(jobs), concurrency, func(i int) {
+		j := jobs[i]
+		channelMessages, err := j.svc.GetHistory(j.channel, j.query)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s: %s", j.channel.Workspace, j.channel.Name, err))
+		}
+		messages = append(messages, channelMessages...)
+		progress.Increment()
+	})
+	progress.Done()
+	for _, err := range errs {
+		log.Printf("Failed to fetch %s", err)
+	}
+	return messages
+}
+
+// parseSince parses a duration relative to now, e.g. '2h', or a date.
+func parseSince(value string) (time.Time, error) {
+	duration, err := time.ParseDuration(value)
+	if err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return parseDate(value, false)
+}
+
+// parseInterspersed parses the flags found anywhere in args and returns the
+// remaining arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-	fmt.Println()
 }
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+const maxBodyLength = 200
+
+// Sender delivers a desktop notification.
+type Sender interface {
+	Send(summary string, body string) error
+}
+
+// DBusSender sends the notifications through the
+// org.freedesktop.Notifications service.
+type DBusSender struct {
+	object dbus.BusObject
+}
+
+// NewDBusSender creates a sender using the connection, e.g. a private bus in
+// tests.
+func NewDBusSender(conn *dbus.Conn) *DBusSender {
+	return &DBusSender{
+		object: conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications"),
+	}
+}
+
+// SessionBusSender creates a sender using the session bus.
+func SessionBusSender() (*DBusSender, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	return NewDBusSender(conn), nil
+}
+
+// Send calls the Notify method, see
+// https://developer.gnome.org/notification-spec/#command-notify
+func (s *DBusSender) Send(summary string, body string) error {
+	call := s.object.Call(
+		"org.freedesktop.Notifications.Notify", 0,
+		"slag",                    // app_name
+		uint32(0),                 // replaces_id
+		"",                        // app_icon
+		summary,                   // summary
+		body,                      // body
+		[]string{},                // actions
+		map[string]dbus.Variant{}, // hints
+		int32(-1),                 // expire_timeout
+	)
+	return call.Err
+}
+
+// Rules decide which messages trigger a notification and how often.
+type Rules struct {
+	// MutedChannels never trigger notifications.
+	MutedChannels []*regexp.Regexp
+	// IgnoredUsers never trigger notifications, e.g. the current user.
+	IgnoredUsers []string
+	// Burst notifications can be sent at once, then one per Interval.
+	Burst    int
+	Interval time.Duration
+}
+
+// DefaultRules allows bursts of 3 notifications, then one every 10 seconds.
+var DefaultRules = Rules{Burst: 3, Interval: 10 * time.Second}
+
+// Notifier is a render.Renderer sending a notification for the direct
+// messages, the mentions and the keywords (see render.Options.IsAlert).
+// Notifications over the rate limit are dropped and counted in the next one.
+type Notifier struct {
+	sender  Sender
+	options render.Options
+	rules   Rules
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	tokens  float64
+	updated time.Time
+	dropped int
+}
+
+func New(sender Sender, options render.Options, rules Rules) *Notifier {
+	return &Notifier{
+		sender:  sender,
+		options: options,
+		rules:   rules,
+		now:     time.Now,
+		tokens:  float64(rules.Burst),
+	}
+}
+
+func (n *Notifier) Start() error {
+	return nil
+}
+
+func (n *Notifier) Message(message components.Message) error {
+	if !n.shouldNotify(message) {
+		return nil
+	}
+	n.mutex.Lock()
+	allowed := n.take()
+	dropped := n.dropped
+	if allowed {
+		n.dropped = 0
+	} else {
+		n.dropped++
+	}
+	n.mutex.Unlock()
+	if !allowed {
+		return nil
+	}
+
+	body := message.Content
+	if len([]rune(body)) > maxBodyLength {
+		body = string([]rune(body)[:maxBodyLength]) + "…"
+	}
+	if dropped > 0 {
+		body = fmt.Sprintf("%s\n(+%d more)", body, dropped)
+	}
+	// A notification failure must not stop the stream.
+	err := n.sender.Send(n.summary(message), body)
+	if err != nil {
+		log.Printf("Failed to send the notification: %s", err)
+	}
+	return nil
+}
+
+func (n *Notifier) Edit(edit components.Edit) error {
+	return nil
+}
+
+func (n *Notifier) Delete(deletion components.Deletion) error {
+	return nil
+}
+
+func (n *Notifier) Gap(gap components.Gap) error {
+	return nil
+}
+
+func (n *Notifier) Reaction(reaction components.Reaction) error {
+	return nil
+}
+
+func (n *Notifier) End() error {
+	return nil
+}
+
+func (n *Notifier) shouldNotify(message components.Message) bool {
+	if message.Channel == nil {
+		return false
+	}
+	for _, user := range n.rules.IgnoredUsers {
+		if message.Name == user {
+			return false
+		}
+	}
+	for _, r := range n.rules.MutedChannels {
+		if r.MatchString(message.Channel.Name) {
+			return false
+		}
+	}
+	return message.Channel.Type == components.ChannelTypeIM || n.options.IsAlert(message)
+}
+
+func (n *Notifier) summary(message components.Message) string {
+	workspace := ""
+	if n.options.ShowWorkspace {
+		workspace = " (" + message.Channel.Workspace + ")"
+	}
+	if message.Channel.Type == components.ChannelTypeIM {
+		return fmt.Sprintf("@%s%s", message.Name, workspace)
+	}
+	return fmt.Sprintf("@%s in #%s%s", message.Name, message.Channel.Name, workspace)
+}
+
+// take consumes a token of the bucket, refilled at one token per interval.
+// The mutex must be held.
+func (n *Notifier) take() bool {
+	now := n.now()
+	if !n.updated.IsZero() && n.rules.Interval > 0 {
+		n.tokens += float64(now.Sub(n.updated)) / float64(n.rules.Interval)
+		if n.tokens > float64(n.rules.Burst) {
+			n.tokens = float64(n.rules.Burst)
+		}
+	}
+	n.updated = now
+	if n.tokens < 1 {
+		return false
+	}
+	n.tokens--
+	return true
+}
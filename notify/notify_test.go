@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+type fakeSender struct {
+	notifications []string
+}
+
+func (s *fakeSender) Send(summary string, body string) error {
+	s.notifications = append(s.notifications, summary+": "+body)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	sender := &fakeSender{}
+	now := time.Unix(1538000000, 0)
+	n := New(sender, render.Options{Highlight: []string{"sev1"}}, Rules{
+		MutedChannels: []*regexp.Regexp{regexp.MustCompile("^random$")},
+		IgnoredUsers:  []string{"me"},
+		Burst:         2,
+		Interval:      10 * time.Second,
+	})
+	n.now = func() time.Time { return now }
+
+	general := &components.Channel{Name: "general", Type: components.ChannelTypeChannel}
+	random := &components.Channel{Name: "random", Type: components.ChannelTypeChannel}
+	im := &components.Channel{Name: "alice", Type: components.ChannelTypeIM}
+
+	messages := []components.Message{
+		{Channel: general, Name: "bob", Content: "nothing to see"},
+		{Channel: general, Name: "bob", Content: "SEV1 declared"},
+		{Channel: random, Name: "bob", Content: "sev1 in random", Mention: true},
+		{Channel: im, Name: "me", Content: "my own message"},
+		{Channel: im, Name: "alice", Content: "hi"},
+		// Over the rate limit
+		{Channel: general, Name: "carol", Content: "hey", Mention: true},
+	}
+	for _, message := range messages {
+		n.Message(message)
+	}
+	now = now.Add(10 * time.Second)
+	n.Message(components.Message{Channel: general, Name: "carol", Content: "sev1?", Mention: true})
+
+	expected := []string{
+		"@bob in #general: SEV1 declared",
+		"@alice: hi",
+		"@carol in #general: sev1?\n(+1 more)",
+	}
+	if !reflect.DeepEqual(sender.notifications, expected) {
+		t.Errorf("%q not equal to %q", sender.notifications, expected)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const POST_USAGE = `USAGE:
+		slag post [OPTIONS] DOMAIN CHANNEL [TEXT]
+
+ARGUMENTS
+	 DOMAIN    Domain/workspace to use.
+	 CHANNEL   '#channel' or '@user' for direct messages.
+	 TEXT      Message to post. Read from stdin when omitted.
+
+OPTIONS:
+	 -thread [TS]      Timestamp of the thread to reply to.
+	 -help, -h
+
+The timestamp of the posted message is printed on stdout.
+`
+
+// post sends a message from the terminal.
+func post(args []string) {
+	flags := flag.NewFlagSet("post", flag.ExitOnError)
+	threadTimestamp := flags.String("thread", "", "Timestamp of the thread to reply to.")
+	flags.Usage = func() {
+		fmt.Print(POST_USAGE)
+	}
+	args = parseInterspersed(flags, args)
+	if len(args) < 2 || len(args) > 3 {
+		flags.Usage()
+		log.Fatal("The domain and the channel must be passed as arguments.")
+	}
+
+	var text string
+	if len(args) == 3 {
+		text = args[2]
+	} else {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		text = strings.TrimRight(string(data), "\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		log.Fatal("The message is empty.")
+	}
+
+	svc, err := newService(args[0], false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	channel, err := svc.ResolveChannel(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	timestamp, err := svc.PostMessage(channel.ID, text, *threadTimestamp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(timestamp)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+// progress prints the number of channels fetched so far on a single line,
+// only when stderr is a terminal.
+type progress struct {
+	total   int
+	done    int
+	enabled bool
+}
+
+func newProgress(total int) *progress {
+	return &progress{
+		total:   total,
+		enabled: isatty.IsTerminal(os.Stderr.Fd()) && total > 0,
+	}
+}
+
+// Increment counts a channel as fetched.
+func (p *progress) Increment() {
+	p.done++
+	if p.enabled {
+		fmt.Fprintf(os.Stderr, "\rFetched %d/%d channels", p.done, p.total)
+	}
+}
+
+// Done clears the line.
+func (p *progress) Done() {
+	if p.enabled {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
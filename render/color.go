@@ -0,0 +1,297 @@
+package render
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+func init() {
+	// https://no-color.org
+	if os.Getenv("NO_COLOR") != "" {
+		color.NoColor = true
+	}
+}
+
+// Palette is the range of colors supported by the terminal.
+type Palette int
+
+const (
+	// Palette16 are the 16 basic ANSI colors.
+	Palette16 Palette = iota
+	// Palette256 are the colors of the xterm 256-color palette.
+	Palette256
+	// PaletteTrueColor are the 24-bit RGB colors.
+	PaletteTrueColor
+)
+
+// DetectPalette returns the palette of the terminal, from the COLORTERM and
+// TERM environment variables.
+func DetectPalette() Palette {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return PaletteTrueColor
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return Palette256
+	}
+	return Palette16
+}
+
+type colorKind int
+
+const (
+	basicColor colorKind = iota
+	indexedColor
+	rgbColor
+)
+
+// Color is a foreground color: a basic ANSI color, a color of the 256-color
+// palette or an RGB color. It is approximated on the terminals with a smaller
+// palette.
+type Color struct {
+	kind      colorKind
+	attribute color.Attribute
+	index     int
+	rgb       [3]int
+}
+
+// colorNames are the basic colors, also available as 'bright-<name>'.
+var colorNames = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+// ParseColor parses a color name, e.g. 'red' or 'bright-red', an index of
+// the 256-color palette, e.g. '196', or an RGB color, e.g. '#ff0000'.
+func ParseColor(spec string) (Color, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if attribute, ok := colorNames[strings.TrimPrefix(spec, "bright-")]; ok {
+		if strings.HasPrefix(spec, "bright-") {
+			attribute += color.FgHiBlack - color.FgBlack
+		}
+		return Color{kind: basicColor, attribute: attribute}, nil
+	}
+	if strings.HasPrefix(spec, "#") && len(spec) == 7 {
+		value, err := strconv.ParseUint(spec[1:], 16, 32)
+		if err == nil {
+			return rgb(int(value>>16), int(value>>8&0xff), int(value&0xff)), nil
+		}
+	}
+	if index, err := strconv.Atoi(spec); err == nil && index >= 0 && index < 256 {
+		return Color{kind: indexedColor, index: index}, nil
+	}
+	return Color{}, fmt.Errorf("invalid color: '%s', expected a name, e.g. 'red', a 256-color index or '#rrggbb'", spec)
+}
+
+func rgb(r int, g int, b int) Color {
+	return Color{kind: rgbColor, rgb: [3]int{r, g, b}}
+}
+
+// attributes returns the SGR attributes of the color in the palette.
+func (c Color) attributes(palette Palette) []color.Attribute {
+	switch {
+	case c.kind == basicColor:
+		return []color.Attribute{c.attribute}
+	case c.kind == indexedColor && c.index < 16:
+		return []color.Attribute{basicAttribute(c.index)}
+	case c.kind == indexedColor && palette == Palette16:
+		return []color.Attribute{nearestBasic(indexRGB(c.index))}
+	case c.kind == indexedColor:
+		return []color.Attribute{38, 5, color.Attribute(c.index)}
+	case palette == PaletteTrueColor:
+		return []color.Attribute{38, 2, color.Attribute(c.rgb[0]), color.Attribute(c.rgb[1]), color.Attribute(c.rgb[2])}
+	case palette == Palette256:
+		return []color.Attribute{38, 5, color.Attribute(cubeIndex(c.rgb))}
+	}
+	return []color.Attribute{nearestBasic(c.rgb)}
+}
+
+// basicAttribute returns the attribute of one of the first 16 colors of the
+// 256-color palette.
+func basicAttribute(index int) color.Attribute {
+	if index < 8 {
+		return color.FgBlack + color.Attribute(index)
+	}
+	return color.FgHiBlack + color.Attribute(index-8)
+}
+
+// cubeLevels are the intensities of the 6x6x6 color cube of the 256-color
+// palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// cubeIndex returns the index of the closest color of the color cube.
+func cubeIndex(rgb [3]int) int {
+	index := 16
+	for i, weight := range []int{36, 6, 1} {
+		level := 0
+		for l, intensity := range cubeLevels {
+			if abs(rgb[i]-intensity) < abs(rgb[i]-cubeLevels[level]) {
+				level = l
+			}
+		}
+		index += level * weight
+	}
+	return index
+}
+
+// indexRGB returns the RGB value of a color of the cube or of the grayscale
+// ramp.
+func indexRGB(index int) [3]int {
+	if index >= 232 {
+		gray := 8 + (index-232)*10
+		return [3]int{gray, gray, gray}
+	}
+	index -= 16
+	return [3]int{cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]}
+}
+
+// nearestBasic approximates an RGB color with a basic color, each channel
+// being on or off.
+func nearestBasic(rgb [3]int) color.Attribute {
+	attribute := color.FgBlack
+	for i, value := range rgb {
+		if value >= 128 {
+			attribute += 1 << uint(i)
+		}
+	}
+	return attribute
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// basicPalette are the basic colors assigned to the users and channels,
+// without black and white.
+var basicPalette = []color.Attribute{
+	color.FgRed, color.FgGreen, color.FgYellow, color.FgBlue, color.FgMagenta, color.FgCyan,
+	color.FgHiRed, color.FgHiGreen, color.FgHiYellow, color.FgHiBlue, color.FgHiMagenta, color.FgHiCyan,
+}
+
+// cubePalette are the colors of the color cube assigned to the users and
+// channels: neither grayish nor too dark to read.
+var cubePalette = func() []int {
+	indexes := make([]int, 0)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				spread := math.Max(float64(r), math.Max(float64(g), float64(b))) -
+					math.Min(float64(r), math.Min(float64(g), float64(b)))
+				if spread >= 2 && r+g+b >= 6 {
+					indexes = append(indexes, 16+36*r+6*g+b)
+				}
+			}
+		}
+	}
+	return indexes
+}()
+
+// stableColor derives a color from an ID, so that a user or a channel keeps
+// its color across runs.
+func stableColor(id string, palette Palette) Color {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	sum := hash.Sum32()
+	switch palette {
+	case PaletteTrueColor:
+		return hslColor(float64(sum%360), 0.65, 0.6)
+	case Palette256:
+		return Color{kind: indexedColor, index: cubePalette[sum%uint32(len(cubePalette))]}
+	}
+	return Color{kind: basicColor, attribute: basicPalette[sum%uint32(len(basicPalette))]}
+}
+
+// hslColor converts a hue in degrees, a saturation and a lightness to an RGB
+// color.
+func hslColor(hue float64, saturation float64, lightness float64) Color {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g = chroma, x
+	case hue < 120:
+		r, g = x, chroma
+	case hue < 180:
+		g, b = chroma, x
+	case hue < 240:
+		g, b = x, chroma
+	case hue < 300:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := lightness - chroma/2
+	scale := func(v float64) int {
+		return int(math.Round((v + m) * 255))
+	}
+	return rgb(scale(r), scale(g), scale(b))
+}
+
+// userAttributes returns the attributes of the color of the author of the
+// message: its override, or one derived from its ID.
+func (o Options) userAttributes(message components.Message) []color.Attribute {
+	if c, ok := o.UserColors[message.Name]; ok {
+		return c.attributes(o.Palette)
+	}
+	id := message.UserID
+	if id == "" {
+		id = message.Name
+	}
+	return stableColor(id, o.Palette).attributes(o.Palette)
+}
+
+// channelAttributes returns the attributes of the color of the channel: its
+// override, or one derived from its ID.
+func (o Options) channelAttributes(channel *components.Channel) []color.Attribute {
+	if c, ok := o.ChannelColors[channel.Name]; ok {
+		return c.attributes(o.Palette)
+	}
+	return stableColor(channel.Workspace+"/"+channel.ID, o.Palette).attributes(o.Palette)
+}
+
+func (o Options) userColor(message components.Message) *color.Color {
+	return color.New(o.userAttributes(message)...)
+}
+
+func (o Options) channelColor(channel *components.Channel) *color.Color {
+	return color.New(o.channelAttributes(channel)...)
+}
+
+// UserStyle returns the escape sequence setting the color of the author of
+// the message, for the outputs drawing the screen themselves, e.g. the TUI.
+func (o Options) UserStyle(message components.Message) string {
+	return sgr(o.userAttributes(message))
+}
+
+// ChannelStyle returns the escape sequence setting the color of the channel,
+// see UserStyle.
+func (o Options) ChannelStyle(channel *components.Channel) string {
+	return sgr(o.channelAttributes(channel))
+}
+
+// sgr returns the escape sequence setting the attributes.
+func sgr(attributes []color.Attribute) string {
+	codes := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		codes = append(codes, strconv.Itoa(int(attribute)))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
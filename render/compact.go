@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Compact prints each message on a single line, attachments included.
+type Compact struct {
+	w       io.Writer
+	options Options
+}
+
+func NewCompact(w io.Writer, options Options) Renderer {
+	return &Compact{w: w, options: options}
+}
+
+func (r *Compact) Start() error {
+	return nil
+}
+
+func (r *Compact) Message(message components.Message) error {
+	return r.print(message, "")
+}
+
+func (r *Compact) Edit(edit components.Edit) error {
+	return r.print(Edited(edit, ansiStrike), "(edited)")
+}
+
+func (r *Compact) Delete(deletion components.Deletion) error {
+	return r.print(tombstone(deletion, ansiStrike), "(deleted)")
+}
+
+func (r *Compact) Gap(gap components.Gap) error {
+	_, err := color.New(color.Faint).Fprintf(r.w, "--- %s %s ---\n", channelLabel(gap.Channel, r.options), gap)
+	return err
+}
+
+func (r *Compact) Reaction(reaction components.Reaction) error {
+	_, err := color.New(color.Faint).Fprintf(r.w, "%s %s %s\n",
+		reaction.Time.Format("15:04:05"),
+		channelLabel(reaction.Channel, r.options),
+		reactionText(reaction),
+	)
+	return err
+}
+
+func (r *Compact) End() error {
+	return nil
+}
+
+func (r *Compact) print(message components.Message, marker string) error {
+	alert := r.options.IsAlert(message)
+	r.options.bell(r.w, message, alert)
+	parts := []string{
+		r.options.alertPrefix(alert) + color.MagentaString(message.Time.Format("15:04:05")),
+		r.options.channelColor(message.Channel).Sprint(channelLabel(message.Channel, r.options)),
+	}
+	if message.IsReply {
+		parts = append(parts, "≡")
+	}
+	parts = append(parts, r.options.userColor(message).Sprintf("@%s:", message.Name))
+	if len(message.Content) > 0 {
+		parts = append(parts, oneLine(ansiBody(message, r.options)))
+	}
+	for _, attachment := range message.Attachments {
+		parts = append(parts, color.New(color.Faint).Sprint("| "+oneLine(ansiAttachment(attachment, r.options))))
+	}
+	if len(message.Reactions) > 0 {
+		parts = append(parts, color.New(color.Faint).Sprintf("[%s]", message.Reactions))
+	}
+	if marker != "" {
+		parts = append(parts, color.New(color.Faint).Sprint(marker))
+	}
+	if summary := r.options.threadSummary(message); summary != "" {
+		parts = append(parts, color.New(color.Faint).Sprintf("(%s)", summary))
+	}
+	indentation := r.options.indent(message)
+	if context := r.options.threadContext(message); context != "" {
+		color.New(color.Faint).Fprintln(r.w, indentation+context)
+	}
+	_, err := fmt.Fprintln(r.w, indentation+strings.Join(parts, " "))
+	return err
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,49 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+func ansiStrike(text string) string {
+	if text == "" {
+		return ""
+	}
+	return color.New(color.Faint, color.CrossedOut).Sprint(text)
+}
+
+func markdownStrike(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "~~" + line + "~~"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Edited returns the new version of the edited message. When the original
+// content is known, it is shown first with the style, e.g. 'old → new'.
+func Edited(edit components.Edit, style func(string) string) components.Message {
+	message := edit.Message
+	if edit.Original != nil && edit.Original.Content != message.Content {
+		original := style(edit.Original.Content) + " → "
+		message.Content = original + message.Content
+		if message.Body != nil {
+			message.Body = append([]components.Node{{Type: components.NodeText, Text: original}}, message.Body...)
+		}
+	}
+	return message
+}
+
+// tombstone returns the deleted message, with the original content shown
+// with the style when known.
+func tombstone(deletion components.Deletion, style func(string) string) components.Message {
+	message := deletion.Message()
+	message.Content = style(message.Content)
+	message.Body = nil
+	return message
+}
@@ -0,0 +1,125 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+func ansiHighlight(word string) string {
+	return color.New(color.Bold, color.FgYellow).Sprint(word)
+}
+
+func markdownHighlight(word string) string {
+	return "**" + word + "**"
+}
+
+// highlightedWords returns the keywords and the mention of the user in the
+// workspace of the message, to emphasize.
+func (o Options) highlightedWords(message components.Message) []string {
+	words := make([]string, 0, len(o.Highlight)+1)
+	words = append(words, o.Highlight...)
+	if message.Channel != nil && o.Mentions[message.Channel.Workspace] != "" {
+		words = append(words, o.Mentions[message.Channel.Workspace])
+	}
+	return words
+}
+
+// highlighter returns a function applying the style to the keywords and
+// mentions of a text of the message.
+func (o Options) highlighter(message components.Message, style func(string) string) func(string) string {
+	words := o.highlightedWords(message)
+	return func(text string) string {
+		return highlight(text, words, style)
+	}
+}
+
+// IsAlert returns true when the message mentions the user or contains one of
+// the highlighted keywords.
+func (o Options) IsAlert(message components.Message) bool {
+	return message.Mention || len(matchWords(message.Content, o.highlightedWords(message))) > 0
+}
+
+// alertPrefix returns the mark followed by a space for alerts.
+func (o Options) alertPrefix(alert bool) string {
+	if alert && o.Mark != "" {
+		return o.Mark + " "
+	}
+	return ""
+}
+
+// bell rings the terminal bell for the live alerts, when enabled. The
+// messages fetched, e.g. on startup, do not ring.
+func (o Options) bell(w io.Writer, message components.Message, alert bool) {
+	if alert && message.Live && o.Bell {
+		fmt.Fprint(w, "\a")
+	}
+}
+
+// highlight applies style to every case-insensitive occurrence of the words
+// in text, see matchWords.
+func highlight(text string, words []string, style func(string) string) string {
+	var b strings.Builder
+	end := 0
+	for _, match := range matchWords(text, words) {
+		b.WriteString(text[end:match[0]])
+		b.WriteString(style(text[match[0]:match[1]]))
+		end = match[1]
+	}
+	b.WriteString(text[end:])
+	return b.String()
+}
+
+// matchWords returns the indexes of the case-insensitive occurrences of the
+// words in text. A word does not match within another one, e.g. '@bob' in
+// '@bobby' or 'sev1' in 'sev10'.
+func matchWords(text string, words []string) [][]int {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	// The longest words come first, so that they are preferred to their
+	// prefixes.
+	sort.Slice(quoted, func(i, j int) bool {
+		return len(quoted[i]) > len(quoted[j])
+	})
+	r := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	matches := make([][]int, 0)
+	for _, match := range r.FindAllStringIndex(text, -1) {
+		if onWordBoundaries(text, match[0], match[1]) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// onWordBoundaries returns whether text[start:end] is not preceded or
+// followed by a word character, on its sides starting or ending with one.
+func onWordBoundaries(text string, start int, end int) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	if previous, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(first) && isWordRune(previous) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	if next, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(last) && isWordRune(next) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
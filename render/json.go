@@ -0,0 +1,45 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/j-martin/slag/components"
+)
+
+// JSON prints one components.MessageRecord per line (NDJSON).
+type JSON struct {
+	encoder *json.Encoder
+}
+
+func NewJSON(w io.Writer, options Options) Renderer {
+	return &JSON{encoder: json.NewEncoder(w)}
+}
+
+func (r *JSON) Start() error {
+	return nil
+}
+
+func (r *JSON) Message(message components.Message) error {
+	return r.encoder.Encode(components.NewMessageRecord(message))
+}
+
+func (r *JSON) Edit(edit components.Edit) error {
+	return r.encoder.Encode(components.NewEditRecord(edit))
+}
+
+func (r *JSON) Delete(deletion components.Deletion) error {
+	return r.encoder.Encode(components.NewDeletionRecord(deletion))
+}
+
+func (r *JSON) Gap(gap components.Gap) error {
+	return r.encoder.Encode(components.NewGapRecord(gap))
+}
+
+func (r *JSON) Reaction(reaction components.Reaction) error {
+	return r.encoder.Encode(components.NewReactionRecord(reaction))
+}
+
+func (r *JSON) End() error {
+	return nil
+}
@@ -0,0 +1,33 @@
+package render
+
+import (
+	"github.com/j-martin/slag/components"
+)
+
+// osc8 returns the label as a terminal hyperlink to the URL.
+//
+// https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feaf
+func osc8(url string, label string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + label + "\x1b]8;;\x1b\\"
+}
+
+// link returns the label as a hyperlink to the URL, or 'label (url)' without
+// hyperlinks. The URL is the label when it is empty.
+func (o Options) link(url string, label string) string {
+	if !o.Hyperlinks {
+		return components.LinkText(url, label)
+	}
+	if label == "" {
+		label = url
+	}
+	return osc8(url, label)
+}
+
+// shortLink returns the label as a hyperlink to the URL, or only the URL
+// without hyperlinks, e.g. for the permalinks.
+func (o Options) shortLink(url string, label string) string {
+	if !o.Hyperlinks {
+		return url
+	}
+	return osc8(url, label)
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Markdown prints messages as markdown, e.g. to paste a conversation into a
+// document or an incident report.
+type Markdown struct {
+	w       io.Writer
+	options Options
+}
+
+func NewMarkdown(w io.Writer, options Options) Renderer {
+	return &Markdown{w: w, options: options}
+}
+
+func (r *Markdown) Start() error {
+	return nil
+}
+
+func (r *Markdown) Message(message components.Message) error {
+	return r.print(message, "")
+}
+
+func (r *Markdown) Edit(edit components.Edit) error {
+	return r.print(Edited(edit, markdownStrike), " _(edited)_")
+}
+
+func (r *Markdown) Delete(deletion components.Deletion) error {
+	return r.print(tombstone(deletion, markdownStrike), " _(deleted)_")
+}
+
+func (r *Markdown) Gap(gap components.Gap) error {
+	_, err := fmt.Fprintf(r.w, "_**%s**: %s_\n\n---\n", channelLabel(gap.Channel, r.options), gap)
+	return err
+}
+
+func (r *Markdown) Reaction(reaction components.Reaction) error {
+	_, err := fmt.Fprintf(r.w, "_**%s** · [%s](%s) · %s_\n\n---\n",
+		channelLabel(reaction.Channel, r.options),
+		reaction.Time.UTC().Format(time.RFC3339),
+		reaction.Target().Permalink(),
+		reactionText(reaction),
+	)
+	return err
+}
+
+func (r *Markdown) End() error {
+	return nil
+}
+
+func (r *Markdown) print(message components.Message, marker string) error {
+	reply := ""
+	if message.IsReply {
+		reply = " · reply"
+	}
+	if summary := r.options.threadSummary(message); summary != "" {
+		reply = " · " + summary
+	}
+	// The replies are quoted under their parent in the threaded view.
+	w := r.w
+	if r.options.indent(message) != "" {
+		w = newIndentWriter(r.w, "> ")
+	}
+	if context := r.options.threadContext(message); context != "" {
+		fmt.Fprintf(w, "_%s_\n\n", context)
+	}
+	fmt.Fprintf(w, "%s**%s** · **@%s** · [%s](%s)%s%s\n\n",
+		r.options.alertPrefix(r.options.IsAlert(message)),
+		channelLabel(message.Channel, r.options),
+		message.Name,
+		message.Time.UTC().Format(time.RFC3339),
+		message.Permalink(),
+		reply,
+		marker,
+	)
+	if len(message.Content) > 0 {
+		fmt.Fprintln(w, quote(markdownBody(message, r.options)))
+		fmt.Fprintln(w)
+	}
+	for _, attachment := range message.Attachments {
+		fmt.Fprintf(w, "- %s\n", strings.Replace(markdownAttachment(attachment), "\n", "\n  ", -1))
+	}
+	if len(message.Attachments) > 0 {
+		fmt.Fprintln(w)
+	}
+	if len(message.Reactions) > 0 {
+		fmt.Fprintf(w, "%s\n\n", message.Reactions)
+	}
+	_, err := fmt.Fprintln(w, "---")
+	return err
+}
+
+func quote(text string) string {
+	return "> " + strings.Replace(text, "\n", "\n> ", -1)
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+// styles are the ANSI attributes of the inline nodes.
+var styles = map[components.NodeType]color.Attribute{
+	components.NodeBold:      color.Bold,
+	components.NodeItalic:    color.Italic,
+	components.NodeStrike:    color.CrossedOut,
+	components.NodeCode:      color.ReverseVideo,
+	components.NodeCodeBlock: color.ReverseVideo,
+}
+
+// body returns the parsed content of the message, or its plain content when
+// it was not parsed.
+func body(message components.Message) []components.Node {
+	if message.Body == nil && message.Content != "" {
+		return []components.Node{{Type: components.NodeText, Text: message.Content}}
+	}
+	return message.Body
+}
+
+// attachmentBody returns the parsed content of the attachment, or its plain
+// content when it was not parsed.
+func attachmentBody(attachment components.Attachment) []components.Node {
+	if attachment.Body == nil {
+		return []components.Node{{Type: components.NodeText, Text: attachment.Content}}
+	}
+	return attachment.Body
+}
+
+// startsWithBlock returns whether the content of the message starts with a
+// block, which must then start on its own line.
+func startsWithBlock(message components.Message) bool {
+	if len(message.Body) == 0 {
+		return false
+	}
+	switch message.Body[0].Type {
+	case components.NodeCodeBlock, components.NodeQuote, components.NodeListItem:
+		return true
+	}
+	return false
+}
+
+// ansiBody renders the content of the message with ANSI styles: bold,
+// italic, strikethrough, reverse video for the code, indented quotes and
+// hyperlinks. The keywords and mentions are highlighted.
+func ansiBody(message components.Message, options Options) string {
+	var b strings.Builder
+	writeANSI(&b, body(message), nil, options.highlighter(message, ansiHighlight), options)
+	return b.String()
+}
+
+// ansiAttachment renders the content of the attachment with its hyperlinks.
+func ansiAttachment(attachment components.Attachment, options Options) string {
+	var b strings.Builder
+	writeANSI(&b, attachmentBody(attachment), nil, plain, options)
+	return b.String()
+}
+
+func writeANSI(b *strings.Builder, nodes []components.Node, attributes []color.Attribute, highlight func(string) string, options Options) {
+	for _, node := range nodes {
+		switch node.Type {
+		case components.NodeText:
+			b.WriteString(ansiText(highlight(node.Text), attributes))
+		case components.NodeCode, components.NodeCodeBlock:
+			b.WriteString(ansiText(node.Text, with(attributes, styles[node.Type])))
+		case components.NodeQuote:
+			var quoted strings.Builder
+			writeANSI(&quoted, node.Children, attributes, highlight, options)
+			bar := "  " + color.New(color.Faint).Sprint("│") + " "
+			b.WriteString(bar + strings.Replace(quoted.String(), "\n", "\n"+bar, -1))
+		case components.NodeListItem:
+			b.WriteString(node.Text + " ")
+			writeANSI(b, node.Children, attributes, highlight, options)
+		case components.NodeLink:
+			var label strings.Builder
+			writeANSI(&label, node.Children, attributes, highlight, options)
+			b.WriteString(options.link(node.Text, label.String()))
+		default:
+			writeANSI(b, node.Children, with(attributes, styles[node.Type]), highlight, options)
+		}
+	}
+}
+
+// with returns a copy of the attributes with one more.
+func with(attributes []color.Attribute, attribute color.Attribute) []color.Attribute {
+	return append(append([]color.Attribute{}, attributes...), attribute)
+}
+
+// ansiText applies the attributes to every line of the text. The styles
+// reset within the text, e.g. by a highlight, are applied again.
+func ansiText(text string, attributes []color.Attribute) string {
+	if len(attributes) == 0 || color.NoColor {
+		return text
+	}
+	set := sgr(attributes)
+	reset := "\x1b[0m"
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = set + strings.Replace(line, reset, reset+set, -1) + reset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownMarkers are the Markdown markers of the inline styles.
+var markdownMarkers = map[components.NodeType]string{
+	components.NodeBold:   "**",
+	components.NodeItalic: "_",
+	components.NodeStrike: "~~",
+}
+
+// markdownBody renders the content of the message in Markdown. The keywords
+// and mentions are highlighted.
+func markdownBody(message components.Message, options Options) string {
+	var b strings.Builder
+	writeMarkdown(&b, body(message), options.highlighter(message, markdownHighlight))
+	return b.String()
+}
+
+// markdownAttachment renders the content of the attachment in Markdown.
+func markdownAttachment(attachment components.Attachment) string {
+	var b strings.Builder
+	writeMarkdown(&b, attachmentBody(attachment), plain)
+	return b.String()
+}
+
+// plain leaves the text of the attachments unhighlighted.
+func plain(text string) string {
+	return text
+}
+
+func writeMarkdown(b *strings.Builder, nodes []components.Node, highlight func(string) string) {
+	for _, node := range nodes {
+		switch node.Type {
+		case components.NodeText:
+			b.WriteString(highlight(node.Text))
+		case components.NodeBold, components.NodeItalic, components.NodeStrike:
+			b.WriteString(markdownMarkers[node.Type])
+			writeMarkdown(b, node.Children, highlight)
+			b.WriteString(markdownMarkers[node.Type])
+		case components.NodeCode:
+			if strings.Contains(node.Text, "`") {
+				b.WriteString("`` " + node.Text + " ``")
+			} else {
+				b.WriteString("`" + node.Text + "`")
+			}
+		case components.NodeCodeBlock:
+			b.WriteString("```\n" + node.Text + "\n```")
+		case components.NodeQuote:
+			var quoted strings.Builder
+			writeMarkdown(&quoted, node.Children, highlight)
+			b.WriteString(quote(quoted.String()))
+		case components.NodeListItem:
+			marker := strings.TrimSpace(node.Text)
+			if !strings.HasSuffix(marker, ".") {
+				marker = "-"
+			}
+			b.WriteString(marker + " ")
+			writeMarkdown(b, node.Children, highlight)
+		case components.NodeLink:
+			if len(node.Children) == 0 {
+				b.WriteString("<" + node.Text + ">")
+				continue
+			}
+			b.WriteString("[")
+			writeMarkdown(b, node.Children, highlight)
+			b.WriteString("](" + node.Text + ")")
+		}
+	}
+}
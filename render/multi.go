@@ -0,0 +1,76 @@
+package render
+
+import (
+	"github.com/j-martin/slag/components"
+)
+
+type multi []Renderer
+
+// Multi passes the stream to all the renderers, in order. The first error is
+// returned.
+func Multi(renderers ...Renderer) Renderer {
+	return multi(renderers)
+}
+
+func (m multi) Start() error {
+	for _, r := range m {
+		if err := r.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Message(message components.Message) error {
+	for _, r := range m {
+		if err := r.Message(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Edit(edit components.Edit) error {
+	for _, r := range m {
+		if err := r.Edit(edit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Delete(deletion components.Deletion) error {
+	for _, r := range m {
+		if err := r.Delete(deletion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Gap(gap components.Gap) error {
+	for _, r := range m {
+		if err := r.Gap(gap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) Reaction(reaction components.Reaction) error {
+	for _, r := range m {
+		if err := r.Reaction(reaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multi) End() error {
+	for _, r := range m {
+		if err := r.End(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/j-martin/slag/components"
+)
+
+// maxReactionContext is the number of runes of the reacted message shown
+// with a reaction.
+const maxReactionContext = 60
+
+// reactionText describes the reaction, with the beginning of the reacted
+// message when known, e.g. '@alice reacted 👍 to @bob: deploying now'.
+func reactionText(reaction components.Reaction) string {
+	verb := "reacted %s to"
+	if reaction.Removed {
+		verb = "removed %s from"
+	}
+	text := fmt.Sprintf("@%s "+verb, reaction.Name, reaction.Emoji)
+	if reaction.Message == nil {
+		return text + " a message"
+	}
+	text += fmt.Sprintf(" @%s", reaction.Message.Name)
+	if context := truncate(oneLine(reaction.Message.Content), maxReactionContext); context != "" {
+		text += ": " + context
+	}
+	return text
+}
+
+// truncate shortens the text to max runes, with an ellipsis.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Renderer receives the message stream, historical and live, and writes it
+// out in its own format. Start is called once before the first message and
+// End once the stream is over. Gap is called before the messages fetched
+// after a reconnection, Reaction whenever a reaction is added or removed.
+// A Renderer is a service.Sink, it receives the live events.
+type Renderer interface {
+	Start() error
+	Message(message components.Message) error
+	Edit(edit components.Edit) error
+	Delete(deletion components.Deletion) error
+	Gap(gap components.Gap) error
+	Reaction(reaction components.Reaction) error
+	End() error
+}
+
+// Options are the settings shared by all renderers.
+type Options struct {
+	// Highlight lists the keywords emphasized in the message content.
+	Highlight []string
+	// Mentions are the spans emphasized in the message content when they
+	// refer to the user, by workspace, e.g. 'acme': '@erroneousboat'.
+	Mentions map[string]string
+	// Bell rings the terminal bell on mentions and keywords.
+	Bell bool
+	// Mark prefixes the messages with mentions or keywords, so they can be
+	// grepped.
+	Mark string
+	// ShowWorkspace prefixes the channels with their workspace, when
+	// aggregating multiple workspaces.
+	ShowWorkspace bool
+	// Threaded indents the replies under their parent, summarizes the
+	// threads, and shows the parent of the live replies.
+	Threaded bool
+	// Hyperlinks writes the links as OSC 8 hyperlinks, showing only their
+	// label, for the terminals supporting them.
+	Hyperlinks bool
+	// Palette is the range of colors of the terminal, for the colors of the
+	// users and channels.
+	Palette Palette
+	// UserColors and ChannelColors are the colors of some users and
+	// channels, by name, instead of the ones derived from their IDs.
+	UserColors    map[string]Color
+	ChannelColors map[string]Color
+}
+
+// channelLabel returns the channel name, prefixed with its workspace when
+// required.
+func channelLabel(channel *components.Channel, options Options) string {
+	if options.ShowWorkspace {
+		return fmt.Sprintf("%s/#%s", channel.Workspace, channel.Name)
+	}
+	return "#" + channel.Name
+}
+
+// Factory creates a Renderer writing to w.
+type Factory func(w io.Writer, options Options) Renderer
+
+var registry = make(map[string]Factory)
+
+// Register makes a renderer available under the given name. It panics if the
+// name is already taken.
+func Register(name string, factory Factory) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("render: renderer '%s' registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// New returns the renderer registered under name.
+func New(name string, w io.Writer, options Options) (Renderer, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: '%s'", name)
+	}
+	return factory(w, options), nil
+}
+
+// Names returns the sorted names of the registered renderers.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("compact", NewCompact)
+	Register("verbose", NewVerbose)
+	Register("text", NewVerbose)
+	Register("markdown", NewMarkdown)
+	Register("json", NewJSON)
+	Register("ndjson", NewJSON)
+}
@@ -0,0 +1,225 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		if _, err := New(name, &bytes.Buffer{}, Options{}); err != nil {
+			t.Errorf("'%s': %s", name, err)
+		}
+	}
+	if _, err := New("unknown", &bytes.Buffer{}, Options{}); err == nil {
+		t.Error("expected an error for an unknown renderer")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	color.NoColor = true
+	buf := &bytes.Buffer{}
+	r := NewCompact(buf, Options{Highlight: []string{"WORLD"}})
+	message := components.Message{
+		Time:        time.Date(2018, 9, 26, 22, 13, 20, 0, time.Local),
+		Channel:     &components.Channel{ID: "C123", Name: "general"},
+		Name:        "bob",
+		Content:     "hello\nworld",
+		Attachments: []components.Attachment{{Content: "a title", Type: "title"}},
+	}
+	edited := message
+	edited.Content = "hello there"
+	r.Start()
+	r.Message(message)
+	r.Edit(components.Edit{Message: message})
+	r.Edit(components.Edit{Message: edited, Original: &message})
+	r.Delete(components.Deletion{Channel: message.Channel, Time: message.Time, Original: &edited})
+	r.Delete(components.Deletion{Channel: message.Channel, Time: message.Time})
+	r.Gap(components.Gap{Channel: message.Channel, Since: message.Time, Until: message.Time, Count: 2})
+	reacted := message
+	reacted.Reactions = components.Reactions{{Name: "+1", Emoji: "👍", Count: 2}}
+	r.Reaction(components.Reaction{Channel: message.Channel, Time: message.Time, Name: "alice", Emoji: "👍", Message: &reacted})
+	r.Reaction(components.Reaction{Channel: message.Channel, Time: message.Time, Name: "alice", Emoji: "👍", Removed: true})
+	r.Message(reacted)
+	r.End()
+	expected := "22:13:20 #general @bob: hello world | a title\n" +
+		"22:13:20 #general @bob: hello world | a title (edited)\n" +
+		"22:13:20 #general @bob: hello world → hello there | a title (edited)\n" +
+		"22:13:20 #general @bob: hello there | a title (deleted)\n" +
+		"22:13:20 #general @unknown: (deleted)\n" +
+		"--- #general reconnected, 2 messages missed since 22:13:20 ---\n" +
+		"22:13:20 #general @alice reacted 👍 to @bob: hello world\n" +
+		"22:13:20 #general @alice removed 👍 from a message\n" +
+		"22:13:20 #general @bob: hello world | a title [👍 2]\n"
+	if buf.String() != expected {
+		t.Errorf("'%s' not equal to '%s'", buf.String(), expected)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	color.NoColor = true
+	buf := &bytes.Buffer{}
+	r := NewCompact(buf, Options{Highlight: []string{"sev1"}, Mentions: map[string]string{"acme": "@bob"}, Mark: "[!]", Bell: true})
+	channel := &components.Channel{ID: "C123", Workspace: "acme", Name: "general"}
+	other := &components.Channel{ID: "C456", Workspace: "other", Name: "general"}
+	at := time.Date(2018, 9, 26, 22, 13, 20, 0, time.Local)
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "SEV1 ongoing", Live: true})
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "ping @bob", Live: true})
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "everyone", Mention: true, Live: true})
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "nothing", Live: true})
+	// Fetched, not live: marked without ringing.
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "sev1 again"})
+	// Not on word boundaries, or mentioning a namesake in another workspace.
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "ping @bobby about sev10", Live: true})
+	r.Message(components.Message{Time: at, Channel: other, Name: "alice", Content: "ping @bob", Live: true})
+	expected := "\a[!] 22:13:20 #general @alice: SEV1 ongoing\n" +
+		"\a[!] 22:13:20 #general @alice: ping @bob\n" +
+		"\a[!] 22:13:20 #general @alice: everyone\n" +
+		"22:13:20 #general @alice: nothing\n" +
+		"[!] 22:13:20 #general @alice: sev1 again\n" +
+		"22:13:20 #general @alice: ping @bobby about sev10\n" +
+		"22:13:20 #general @alice: ping @bob\n"
+	if buf.String() != expected {
+		t.Errorf("%q not equal to %q", buf.String(), expected)
+	}
+}
+
+func TestThreaded(t *testing.T) {
+	color.NoColor = true
+	buf := &bytes.Buffer{}
+	r := NewCompact(buf, Options{Threaded: true})
+	channel := &components.Channel{ID: "C123", Name: "general"}
+	at := time.Date(2018, 9, 26, 22, 13, 20, 0, time.Local)
+	parent := components.Message{Time: at, Channel: channel, Name: "bob", Content: "deploying\nnow", ReplyCount: 1, LastReply: at}
+	r.Message(parent)
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "ok", IsReply: true})
+	r.Message(components.Message{Time: at, Channel: channel, Name: "alice", Content: "done?", IsReply: true, Parent: &parent})
+	expected := "22:13:20 #general @bob: deploying now (1 reply, last 22:13)\n" +
+		"    22:13:20 #general ≡ @alice: ok\n" +
+		"    ↳ re @bob: deploying\n" +
+		"    22:13:20 #general ≡ @alice: done?\n"
+	if buf.String() != expected {
+		t.Errorf("%q not equal to %q", buf.String(), expected)
+	}
+}
+
+func TestMrkdwn(t *testing.T) {
+	message := components.Message{
+		Content: "> bold code sev1 docs (https://example.com)",
+		Body: []components.Node{
+			{Type: components.NodeQuote, Children: []components.Node{
+				{Type: components.NodeBold, Children: []components.Node{{Type: components.NodeText, Text: "bold"}}},
+				{Type: components.NodeText, Text: " "},
+				{Type: components.NodeCode, Text: "code"},
+				{Type: components.NodeText, Text: " sev1 "},
+				{Type: components.NodeLink, Text: "https://example.com", Children: []components.Node{{Type: components.NodeText, Text: "docs"}}},
+			}},
+		},
+	}
+	options := Options{Highlight: []string{"SEV1"}}
+
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+	ansi := ansiBody(message, options)
+	expected := "  \x1b[2m│\x1b[0m \x1b[1mbold\x1b[0m \x1b[7mcode\x1b[0m \x1b[1;33msev1\x1b[0m docs (https://example.com)"
+	if ansi != expected {
+		t.Errorf("%q not equal to %q", ansi, expected)
+	}
+
+	options.Hyperlinks = true
+	ansi = ansiBody(message, options)
+	expected = "  \x1b[2m│\x1b[0m \x1b[1mbold\x1b[0m \x1b[7mcode\x1b[0m \x1b[1;33msev1\x1b[0m \x1b]8;;https://example.com\x1b\\docs\x1b]8;;\x1b\\"
+	if ansi != expected {
+		t.Errorf("%q not equal to %q", ansi, expected)
+	}
+
+	markdown := markdownBody(message, options)
+	expected = "> **bold** `code` **sev1** [docs](https://example.com)"
+	if markdown != expected {
+		t.Errorf("%q not equal to %q", markdown, expected)
+	}
+
+	attachment := components.Attachment{
+		Content: "1 new commit (https://example.com/commit)",
+		Type:    "text",
+		Body: []components.Node{
+			{Type: components.NodeLink, Text: "https://example.com/commit", Children: []components.Node{{Type: components.NodeText, Text: "1 new commit"}}},
+		},
+	}
+	ansi = ansiAttachment(attachment, options)
+	expected = "\x1b]8;;https://example.com/commit\x1b\\1 new commit\x1b]8;;\x1b\\"
+	if ansi != expected {
+		t.Errorf("%q not equal to %q", ansi, expected)
+	}
+	markdown = markdownAttachment(attachment)
+	expected = "[1 new commit](https://example.com/commit)"
+	if markdown != expected {
+		t.Errorf("%q not equal to %q", markdown, expected)
+	}
+}
+
+func TestColors(t *testing.T) {
+	cases := []struct {
+		spec     string
+		palette  Palette
+		expected []color.Attribute
+	}{
+		{"red", PaletteTrueColor, []color.Attribute{color.FgRed}},
+		{"Bright-Cyan", Palette16, []color.Attribute{color.FgHiCyan}},
+		{"#ff8700", PaletteTrueColor, []color.Attribute{38, 2, 255, 135, 0}},
+		{"#ff8700", Palette256, []color.Attribute{38, 5, 208}},
+		{"#ff8700", Palette16, []color.Attribute{color.FgYellow}},
+		{"208", Palette256, []color.Attribute{38, 5, 208}},
+		{"208", Palette16, []color.Attribute{color.FgYellow}},
+		{"9", Palette256, []color.Attribute{color.FgHiRed}},
+	}
+	for _, c := range cases {
+		parsed, err := ParseColor(c.spec)
+		if err != nil {
+			t.Errorf("'%s': %s", c.spec, err)
+			continue
+		}
+		if attributes := parsed.attributes(c.palette); !reflect.DeepEqual(attributes, c.expected) {
+			t.Errorf("'%s': %v not equal to %v", c.spec, attributes, c.expected)
+		}
+	}
+	for _, spec := range []string{"", "orange", "256", "#ff87"} {
+		if _, err := ParseColor(spec); err == nil {
+			t.Errorf("'%s': expected an error", spec)
+		}
+	}
+
+	color.NoColor = false
+	defer func() { color.NoColor = true }()
+	for _, palette := range []Palette{Palette16, Palette256, PaletteTrueColor} {
+		options := Options{Palette: palette}
+		bob := components.Message{UserID: "U1", Name: "bob"}
+		if first, second := options.userColor(bob).Sprint("@bob"), options.userColor(bob).Sprint("@bob"); first != second {
+			t.Errorf("%q not equal to %q", first, second)
+		}
+		// The bots without a user ID are colored after their name.
+		if options.userColor(components.Message{Name: "bot"}).Sprint("@bot") == "@bot" {
+			t.Error("expected a color for a bot")
+		}
+	}
+
+	incidents := &components.Channel{ID: "C1", Name: "incidents", Workspace: "acme"}
+	red, _ := ParseColor("red")
+	options := Options{Palette: Palette256, ChannelColors: map[string]Color{"incidents": red}}
+	expected := "\x1b[31m#incidents\x1b[0m"
+	if label := options.channelColor(incidents).Sprint("#incidents"); label != expected {
+		t.Errorf("%q not equal to %q", label, expected)
+	}
+	options.ChannelColors = nil
+	expected = fmt.Sprintf("\x1b[38;5;%dm#incidents\x1b[0m", stableColor("acme/C1", Palette256).index)
+	if label := options.channelColor(incidents).Sprint("#incidents"); label != expected {
+		t.Errorf("%q not equal to %q", label, expected)
+	}
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"sync"
+
+	"github.com/j-martin/slag/components"
+)
+
+type synchronized struct {
+	renderer Renderer
+	mutex    sync.Mutex
+}
+
+// Synchronized wraps r so that it can be used from multiple goroutines, e.g.
+// when listening to multiple workspaces.
+func Synchronized(r Renderer) Renderer {
+	return &synchronized{renderer: r}
+}
+
+func (r *synchronized) Start() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Start()
+}
+
+func (r *synchronized) Message(message components.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Message(message)
+}
+
+func (r *synchronized) Edit(edit components.Edit) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Edit(edit)
+}
+
+func (r *synchronized) Delete(deletion components.Deletion) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Delete(deletion)
+}
+
+func (r *synchronized) Gap(gap components.Gap) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Gap(gap)
+}
+
+func (r *synchronized) Reaction(reaction components.Reaction) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.Reaction(reaction)
+}
+
+func (r *synchronized) End() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.renderer.End()
+}
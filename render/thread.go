@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/j-martin/slag/components"
+)
+
+// replyIndent is the indentation of the replies in the threaded view.
+const replyIndent = "    "
+
+// indent returns the indentation of the message, replies are indented under
+// their parent in the threaded view.
+func (o Options) indent(message components.Message) string {
+	if o.Threaded && message.IsReply {
+		return replyIndent
+	}
+	return ""
+}
+
+// threadSummary describes the thread of a parent message in the threaded
+// view, e.g. '3 replies, last 15:04'.
+func (o Options) threadSummary(message components.Message) string {
+	if !o.Threaded || message.ReplyCount == 0 {
+		return ""
+	}
+	noun := "replies"
+	if message.ReplyCount == 1 {
+		noun = "reply"
+	}
+	summary := fmt.Sprintf("%d %s", message.ReplyCount, noun)
+	if !message.LastReply.IsZero() {
+		summary += ", last " + message.LastReply.Format("15:04")
+	}
+	return summary
+}
+
+// threadContext returns the first line of the parent of a live reply in the
+// threaded view, e.g. '↳ re @bob: deploying now'.
+func (o Options) threadContext(message components.Message) string {
+	if !o.Threaded || message.Parent == nil {
+		return ""
+	}
+	firstLine := strings.SplitN(strings.TrimSpace(message.Parent.Content), "\n", 2)[0]
+	return fmt.Sprintf("↳ re @%s: %s", message.Parent.Name, truncate(firstLine, maxReactionContext))
+}
+
+// indentWriter prefixes every line written with the indentation.
+type indentWriter struct {
+	w           io.Writer
+	indentation string
+	lineStart   bool
+}
+
+func newIndentWriter(w io.Writer, indentation string) io.Writer {
+	if indentation == "" {
+		return w
+	}
+	return &indentWriter{w: w, indentation: indentation, lineStart: true}
+}
+
+func (w *indentWriter) Write(p []byte) (int, error) {
+	var out []byte
+	for _, b := range p {
+		if w.lineStart && b != '\n' {
+			out = append(out, w.indentation...)
+		}
+		out = append(out, b)
+		w.lineStart = b == '\n'
+	}
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
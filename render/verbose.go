@@ -0,0 +1,112 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Verbose prints each message on multiple lines: time and permalink, then
+// the channel, author and content, followed by the attachments.
+type Verbose struct {
+	w       io.Writer
+	options Options
+}
+
+func NewVerbose(w io.Writer, options Options) Renderer {
+	return &Verbose{w: w, options: options}
+}
+
+func (r *Verbose) Start() error {
+	return nil
+}
+
+func (r *Verbose) Message(message components.Message) error {
+	return r.print(message, "")
+}
+
+func (r *Verbose) Edit(edit components.Edit) error {
+	return r.print(Edited(edit, ansiStrike), "(edited)")
+}
+
+func (r *Verbose) Delete(deletion components.Deletion) error {
+	return r.print(tombstone(deletion, ansiStrike), "(deleted)")
+}
+
+func (r *Verbose) Gap(gap components.Gap) error {
+	_, err := color.New(color.Faint).Fprintf(r.w, "--- [%s] %s ---\n\n", channelLabel(gap.Channel, r.options), gap)
+	return err
+}
+
+func (r *Verbose) Reaction(reaction components.Reaction) error {
+	_, err := color.New(color.Faint).Fprintf(r.w, "%s [%s] %s\n\n",
+		reaction.Time.Format("15:04:05Z07:00"),
+		channelLabel(reaction.Channel, r.options),
+		reactionText(reaction),
+	)
+	return err
+}
+
+func (r *Verbose) End() error {
+	return nil
+}
+
+func (r *Verbose) print(message components.Message, marker string) error {
+	threadSymbol := ""
+	if message.IsReply {
+		threadSymbol = "≡"
+	}
+	faint := color.New(color.Faint)
+	alert := r.options.IsAlert(message)
+	prefix := r.options.alertPrefix(alert)
+	r.options.bell(r.w, message, alert)
+	w := newIndentWriter(r.w, r.options.indent(message))
+	if context := r.options.threadContext(message); context != "" {
+		faint.Fprintln(w, context)
+	}
+	_, err := fmt.Fprintln(w,
+		prefix+color.MagentaString("%s [%s]", message.Time.UTC().Format(time.RFC3339), message.Time.Format("15:04:05Z07:00")),
+		faint.Sprint(r.options.shortLink(message.Permalink(), "permalink")),
+		threadSymbol,
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s%s %s ",
+		prefix,
+		r.options.channelColor(message.Channel).Sprintf("[%s]", channelLabel(message.Channel, r.options)),
+		r.options.userColor(message).Sprintf("@%s", message.Name),
+	)
+	if len(message.Content) > 0 {
+		if startsWithBlock(message) {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, ansiBody(message, r.options))
+	}
+	if marker != "" {
+		fmt.Fprint(w, " ", faint.Sprint(marker))
+	}
+	if summary := r.options.threadSummary(message); summary != "" {
+		fmt.Fprint(w, " ", faint.Sprintf("(%s)", summary))
+	}
+	fmt.Fprintln(w)
+	for _, attachment := range message.Attachments {
+		switch attachment.Type {
+		case "text":
+			fmt.Fprintln(w, ansiAttachment(attachment, r.options))
+		case "header":
+			color.New(color.Bold).Fprintln(w, ansiAttachment(attachment, r.options))
+		default:
+			faint.Fprintln(w, ansiAttachment(attachment, r.options))
+		}
+	}
+	if len(message.Reactions) > 0 {
+		faint.Fprintln(w, message.Reactions.String())
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
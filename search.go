@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/j-martin/slag/archive"
+	"github.com/j-martin/slag/render"
+)
+
+const SEARCH_USAGE = `USAGE:
+		slag search [OPTIONS] DOMAIN [QUERY]
+
+Searches the local archive, without network access. Every message seen by
+slag is archived in '%s', unless -archive=false is passed.
+
+ARGUMENTS
+	 DOMAIN    Domain/workspace to search, as passed to slag or its team
+	           domain, e.g. 'acme' for acme.slack.com.
+	 QUERY     Text to search in the messages and attachments,
+	           case-insensitive. Everything matches when omitted.
+
+OPTIONS:
+	 -user [NAME]      Only the messages of this user.
+	 -channel [NAME]   Only the messages of this channel.
+	 -from [DATE]      Only the messages posted after the date, e.g.
+	                   '2026-10-01' or '2026-10-01T15:04:05Z'.
+	 -to [DATE]        Only the messages posted before the date.
+	 -help, -h
+
+The results are printed with the output format selected by -o, e.g.
+'slag -o json search acme sev1'.
+`
+
+// search looks for messages in the local archive.
+func search(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	user := flags.String("user", "", "Only the messages of this user.")
+	channel := flags.String("channel", "", "Only the messages of this channel.")
+	from := flags.String("from", "", "Only the messages posted after the date.")
+	to := flags.String("to", "", "Only the messages posted before the date.")
+	flags.Usage = func() {
+		fmt.Printf(SEARCH_USAGE, flagArchiveDir)
+	}
+	args = parseInterspersed(flags, args)
+	if len(args) < 1 || len(args) > 2 {
+		flags.Usage()
+		log.Fatal("The domain must be passed as an argument.")
+	}
+
+	loadProfile(args[0])
+	query := archive.Query{
+		Workspace: args[0],
+		User:      *user,
+		Channel:   *channel,
+	}
+	if len(args) == 2 {
+		query.Text = args[1]
+	}
+	var err error
+	if *from != "" {
+		if query.From, err = parseDate(*from, false); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *to != "" {
+		if query.To, err = parseDate(*to, true); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	records, err := archive.New(flagArchiveDir).Search(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	renderer, err := render.New(flagOutputFormat, os.Stdout, renderOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = renderer.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, record := range records {
+		err = renderer.Message(record.Message())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = renderer.End()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseDate parses a date, e.g. '2026-10-01', or a time in the RFC 3339
+// format. A date stands for the start of the day, or its end when endOfDay is
+// set.
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid date: '%s', expected e.g. '2026-10-01' or '2026-10-01T15:04:05Z'", value)
+	}
+	return t, nil
+}
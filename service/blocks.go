@@ -0,0 +1,339 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Block is a Block Kit layout block, only the fields displayed by slag are
+// decoded. The vendored Slack client predates Block Kit and drops the blocks
+// of the messages, they are decoded from the raw payloads instead, see
+// blockRecorder.
+//
+// https://api.slack.com/reference/block-kit/blocks
+type Block struct {
+	Type string `json:"type"`
+	// Text is set for the header and section blocks.
+	Text *BlockText `json:"text"`
+	// Fields are the two-column texts of a section block.
+	Fields []BlockText `json:"fields"`
+	// Accessory is the image or the button next to a section block.
+	Accessory *BlockElement `json:"accessory"`
+	// Elements are the elements of the context, actions and rich_text
+	// blocks.
+	Elements []BlockElement `json:"elements"`
+	// ImageURL, AltText and Title are set for the image blocks.
+	ImageURL string     `json:"image_url"`
+	AltText  string     `json:"alt_text"`
+	Title    *BlockText `json:"title"`
+}
+
+// BlockText is a text object, e.g. {"type": "mrkdwn", "text": "*hi*"}, or
+// the plain string of a rich text element.
+//
+// https://api.slack.com/reference/block-kit/composition-objects#text
+type BlockText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (t *BlockText) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		t.Type = "plain_text"
+		return json.Unmarshal(data, &t.Text)
+	}
+	type object BlockText
+	return json.Unmarshal(data, (*object)(t))
+}
+
+// BlockElement is an element of a block: a text object or an image of a
+// context block, a button, or a rich text element.
+//
+// https://api.slack.com/reference/block-kit/block-elements
+type BlockElement struct {
+	Type string    `json:"type"`
+	Text BlockText `json:"text"`
+	// URL is the link of a button or of a rich text link.
+	URL     string `json:"url"`
+	AltText string `json:"alt_text"`
+	// Elements are the children of the rich text sections, lists, quotes
+	// and preformatted texts.
+	Elements []BlockElement `json:"elements"`
+	// Style is 'bullet' or 'ordered' for the rich text lists.
+	Style  richTextStyle `json:"style"`
+	Indent int           `json:"indent"`
+	// The entities of the rich text sections.
+	UserID      string `json:"user_id"`
+	ChannelID   string `json:"channel_id"`
+	UserGroupID string `json:"usergroup_id"`
+	Name        string `json:"name"`
+	Range       string `json:"range"`
+}
+
+// richTextStyle is the style of a rich text element: the kind of a list, or
+// the styles of a text, which are not displayed.
+type richTextStyle string
+
+func (s *richTextStyle) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		return json.Unmarshal(data, (*string)(s))
+	}
+	return nil
+}
+
+// FormatBlocks converts the blocks of a message to attachments, of the type
+// of the block: 'header', 'text', 'field', 'context', 'divider', 'image',
+// 'button', 'list', 'quote' or 'code'. The rich_text blocks mirror the text
+// of the messages posted by users, they are skipped when the message has a
+// text.
+func (s *SlackService) FormatBlocks(blocks []Block, hasText bool) []components.Attachment {
+	var attachments []components.Attachment
+	add := func(kind string, content string) {
+		if strings.TrimSpace(content) != "" {
+			attachments = append(attachments, components.Attachment{Type: kind, Content: content})
+		}
+	}
+	for _, block := range blocks {
+		switch block.Type {
+		case "header":
+			if block.Text != nil {
+				add("header", s.blockText(*block.Text))
+			}
+
+		case "section":
+			if block.Text != nil {
+				add("text", s.blockText(*block.Text))
+			}
+			for _, field := range block.Fields {
+				add("field", s.blockText(field))
+			}
+			if block.Accessory != nil {
+				kind, content := s.blockElement(*block.Accessory)
+				add(kind, content)
+			}
+
+		case "context":
+			parts := make([]string, 0, len(block.Elements))
+			for _, element := range block.Elements {
+				if _, content := s.blockElement(element); content != "" {
+					parts = append(parts, content)
+				}
+			}
+			add("context", strings.Join(parts, "  "))
+
+		case "divider":
+			add("divider", "────────")
+
+		case "image":
+			content := block.AltText
+			if block.Title != nil {
+				content = s.blockText(*block.Title)
+			}
+			add("image", fmt.Sprintf("%s ⇒ %s", content, block.ImageURL))
+
+		case "actions":
+			for _, element := range block.Elements {
+				add(s.blockElement(element))
+			}
+
+		case "rich_text":
+			if hasText {
+				continue
+			}
+			for _, element := range block.Elements {
+				add(s.richTextBlock(element))
+			}
+		}
+	}
+	return attachments
+}
+
+// blockText returns the text of a text object, the mrkdwn is resolved like
+// the text of the messages.
+func (s *SlackService) blockText(text BlockText) string {
+	if text.Type == "mrkdwn" {
+		return components.PlainText(parseMessage(s, text.Text))
+	}
+	return parseEmoji(text.Text)
+}
+
+// blockElement returns the attachment type and the content of an element of
+// a section, context or actions block.
+func (s *SlackService) blockElement(element BlockElement) (string, string) {
+	switch element.Type {
+	case "mrkdwn", "plain_text":
+		return "context", s.blockText(BlockText{Type: element.Type, Text: element.Text.Text})
+	case "image":
+		return "image", element.AltText
+	case "button":
+		label := "[" + s.blockText(element.Text) + "]"
+		if element.URL != "" {
+			label += " ⇒ " + element.URL
+		}
+		return "button", label
+	}
+	// e.g. the menus, with their placeholder.
+	if element.Text.Text != "" {
+		return "button", "[" + s.blockText(element.Text) + "]"
+	}
+	return "", ""
+}
+
+// richTextBlock returns the attachment type and the content of an element of
+// a rich_text block.
+func (s *SlackService) richTextBlock(element BlockElement) (string, string) {
+	switch element.Type {
+	case "rich_text_list":
+		lines := make([]string, 0, len(element.Elements))
+		for i, item := range element.Elements {
+			marker := "•"
+			if element.Style == "ordered" {
+				marker = fmt.Sprintf("%d.", i+1)
+			}
+			lines = append(lines, strings.Repeat("  ", element.Indent)+marker+" "+s.richText(item.Elements))
+		}
+		return "list", strings.Join(lines, "\n")
+	case "rich_text_quote":
+		return "quote", "> " + strings.Replace(s.richText(element.Elements), "\n", "\n> ", -1)
+	case "rich_text_preformatted":
+		return "code", s.richText(element.Elements)
+	}
+	return "text", s.richText(element.Elements)
+}
+
+// richText returns the text of the elements of a rich text section.
+func (s *SlackService) richText(elements []BlockElement) string {
+	var b strings.Builder
+	for _, element := range elements {
+		switch element.Type {
+		case "text":
+			b.WriteString(element.Text.Text)
+		case "link":
+			if element.Text.Text != "" && element.Text.Text != element.URL {
+				b.WriteString(element.Text.Text + " (" + element.URL + ")")
+			} else {
+				b.WriteString(element.URL)
+			}
+		case "user":
+			b.WriteString("@" + s.userName(element.UserID))
+		case "channel":
+			if name, ok := s.channelName(element.ChannelID); ok {
+				b.WriteString("#" + name)
+			} else {
+				b.WriteString("#" + element.ChannelID)
+			}
+		case "usergroup":
+			if group, ok := s.getUserGroup(element.UserGroupID); ok && group.Handle != "" {
+				b.WriteString("@" + group.Handle)
+			} else {
+				b.WriteString("@subteam")
+			}
+		case "emoji":
+			b.WriteString(emoji(element.Name))
+		case "broadcast":
+			b.WriteString("@" + element.Range)
+		}
+	}
+	return b.String()
+}
+
+// blockStore keeps the blocks of the messages until they are created, by
+// channel and timestamp. The oldest blocks are evicted once the capacity is
+// reached, e.g. for the messages fetched but not displayed.
+type blockStore struct {
+	capacity int
+	blocks   map[string][]Block
+	order    []string
+	mutex    sync.Mutex
+}
+
+func newBlockStore(capacity int) *blockStore {
+	return &blockStore{
+		capacity: capacity,
+		blocks:   make(map[string][]Block),
+	}
+}
+
+func (b *blockStore) Put(channelID string, timestamp string, blocks []Block) {
+	if len(blocks) == 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	key := cacheKey(channelID, timestamp)
+	if _, ok := b.blocks[key]; !ok {
+		b.order = append(b.order, key)
+	}
+	b.blocks[key] = blocks
+	for len(b.order) > b.capacity {
+		delete(b.blocks, b.order[0])
+		b.order = b.order[1:]
+	}
+}
+
+// Take returns the blocks of a message and forgets them.
+func (b *blockStore) Take(channelID string, timestamp string) []Block {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	key := cacheKey(channelID, timestamp)
+	blocks := b.blocks[key]
+	delete(b.blocks, key)
+	return blocks
+}
+
+// blockMethods are the Slack API methods whose responses list messages.
+var blockMethods = map[string]bool{
+	"conversations.history": true,
+	"conversations.replies": true,
+}
+
+// blockRecorder is the HTTP client of the Slack client. It reads the blocks
+// of the messages listed in the responses, before the Slack client drops
+// them, and keeps them in the store.
+type blockRecorder struct {
+	client *http.Client
+	store  *blockStore
+}
+
+func (r *blockRecorder) Do(request *http.Request) (*http.Response, error) {
+	var channelID string
+	if blockMethods[path.Base(request.URL.Path)] && request.GetBody != nil {
+		if body, err := request.GetBody(); err == nil {
+			form, _ := ioutil.ReadAll(body)
+			values, _ := url.ParseQuery(string(form))
+			channelID = values.Get("channel")
+		}
+	}
+	response, err := r.client.Do(request)
+	if err != nil || channelID == "" || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var messages struct {
+		Messages []struct {
+			Timestamp string  `json:"ts"`
+			Blocks    []Block `json:"blocks"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &messages) == nil {
+		for _, message := range messages.Messages {
+			r.store.Put(channelID, message.Timestamp, message.Blocks)
+		}
+	}
+	return response, nil
+}
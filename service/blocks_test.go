@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+
+	"github.com/nlopes/slack"
+)
+
+const testBlocks = `[
+	{"type": "header", "text": {"type": "plain_text", "text": "Build failed :x:"}},
+	{"type": "section", "text": {"type": "mrkdwn", "text": "*main* by <@U1>"},
+		"fields": [{"type": "mrkdwn", "text": "*Job*\nunit"}, {"type": "plain_text", "text": "Took 3m"}],
+		"accessory": {"type": "button", "text": {"type": "plain_text", "text": "Logs"}, "url": "https://ci.example.com/1"}},
+	{"type": "context", "elements": [
+		{"type": "image", "image_url": "https://ci.example.com/icon.png", "alt_text": "ci"},
+		{"type": "mrkdwn", "text": "in <#C1>"}]},
+	{"type": "divider"},
+	{"type": "image", "image_url": "https://ci.example.com/graph.png", "alt_text": "graph"},
+	{"type": "actions", "elements": [
+		{"type": "button", "text": {"type": "plain_text", "text": "Retry"}, "value": "retry"},
+		{"type": "static_select", "placeholder": {"type": "plain_text", "text": "Pick"}}]},
+	{"type": "rich_text", "elements": [
+		{"type": "rich_text_section", "elements": [
+			{"type": "text", "text": "ping ", "style": {"bold": true}},
+			{"type": "user", "user_id": "U1"},
+			{"type": "text", "text": " "},
+			{"type": "link", "url": "https://example.com", "text": "docs"},
+			{"type": "emoji", "name": "tada"}]},
+		{"type": "rich_text_list", "style": "ordered", "indent": 1, "elements": [
+			{"type": "rich_text_section", "elements": [{"type": "text", "text": "first"}]},
+			{"type": "rich_text_section", "elements": [{"type": "broadcast", "range": "here"}]}]},
+		{"type": "rich_text_quote", "elements": [{"type": "text", "text": "quoted\nlines"}]},
+		{"type": "rich_text_preformatted", "elements": [{"type": "text", "text": "make test"}]}]}
+]`
+
+func TestFormatBlocks(t *testing.T) {
+	var blocks []Block
+	if err := json.Unmarshal([]byte(testBlocks), &blocks); err != nil {
+		t.Fatal(err)
+	}
+	s := &SlackService{
+		UserCache: map[string]string{"U1": "jane"},
+		Channels:  map[string]components.Channel{"C1": {ID: "C1", Name: "ci"}},
+		mutex:     &sync.Mutex{},
+	}
+
+	expected := []components.Attachment{
+		{Type: "header", Content: "Build failed ❌"},
+		{Type: "text", Content: "main by @jane"},
+		{Type: "field", Content: "Job\nunit"},
+		{Type: "field", Content: "Took 3m"},
+		{Type: "button", Content: "[Logs] ⇒ https://ci.example.com/1"},
+		{Type: "context", Content: "ci  in #ci"},
+		{Type: "divider", Content: "────────"},
+		{Type: "image", Content: "graph ⇒ https://ci.example.com/graph.png"},
+		{Type: "button", Content: "[Retry]"},
+		{Type: "text", Content: "ping @jane docs (https://example.com)🎉"},
+		{Type: "list", Content: "  1. first\n  2. @here"},
+		{Type: "quote", Content: "> quoted\n> lines"},
+		{Type: "code", Content: "make test"},
+	}
+	attachments := s.FormatBlocks(blocks, false)
+	if !reflect.DeepEqual(attachments, expected) {
+		t.Errorf("%+v not equal to %+v", attachments, expected)
+	}
+
+	// The rich text mirrors the text of the message.
+	attachments = s.FormatBlocks(blocks, true)
+	if !reflect.DeepEqual(attachments, expected[:9]) {
+		t.Errorf("%+v not equal to %+v", attachments, expected[:9])
+	}
+}
+
+func TestBlockRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true,"messages":[
+			{"type":"message","bot_id":"B1","username":"ci","text":"","ts":"1538000002.000100",
+				"blocks":[{"type":"header","text":{"type":"plain_text","text":"Deployed"}}]},
+			{"type":"message","user":"U1","text":"hi","ts":"1538000001.000100"}
+		]}`)
+	}))
+	defer server.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = server.URL + "/"
+
+	blocks := newBlockStore(messageCacheCapacity)
+	s := &SlackService{
+		Client:    slack.New("token", slack.OptionHTTPClient(&blockRecorder{client: &http.Client{}, store: blocks})),
+		UserCache: map[string]string{"U1": "jane"},
+		mutex:     &sync.Mutex{},
+		limiter:   NewLimiter(),
+		cache:     newMessageCache(messageCacheCapacity),
+		blocks:    blocks,
+	}
+	messages, err := s.GetHistory(components.Channel{ID: "C1"}, HistoryQuery{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	expected := []components.Attachment{{Type: "header", Content: "Deployed"}}
+	if fmt.Sprint(messages[0].Attachments) != fmt.Sprint(expected) {
+		t.Errorf("%v not equal to %v", messages[0].Attachments, expected)
+	}
+	if len(messages[1].Attachments) != 0 {
+		t.Errorf("unexpected attachments: %v", messages[1].Attachments)
+	}
+	if len(blocks.blocks) != 0 {
+		t.Errorf("the blocks were not taken: %v", blocks.blocks)
+	}
+}
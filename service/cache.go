@@ -0,0 +1,86 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/j-martin/slag/components"
+)
+
+// messageCacheCapacity is the number of messages remembered by the service.
+const messageCacheCapacity = 5000
+
+// messageCache remembers the latest messages created, to show the original
+// content of the messages edited or deleted. The oldest messages are evicted
+// once the capacity is reached.
+type messageCache struct {
+	capacity int
+	messages map[string]components.Message
+	order    []string
+	mutex    sync.Mutex
+}
+
+func newMessageCache(capacity int) *messageCache {
+	return &messageCache{
+		capacity: capacity,
+		messages: make(map[string]components.Message),
+	}
+}
+
+func cacheKey(channelID string, timestamp string) string {
+	return channelID + "/" + timestamp
+}
+
+// Put adds the message, or replaces the version already known.
+func (c *messageCache) Put(message components.Message) {
+	if message.Channel == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := cacheKey(message.Channel.ID, message.Timestamp)
+	if _, ok := c.messages[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.messages[key] = message
+	for len(c.order) > c.capacity {
+		delete(c.messages, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *messageCache) Get(channelID string, timestamp string) (components.Message, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	message, ok := c.messages[cacheKey(channelID, timestamp)]
+	return message, ok
+}
+
+func (c *messageCache) Remove(channelID string, timestamp string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.messages, cacheKey(channelID, timestamp))
+}
+
+// Threads returns the timestamps of the threads of the channel with a
+// cached reply, or a cached parent with replies, active since the time.
+func (c *messageCache) Threads(channelID string, since time.Time) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	seen := make(map[string]bool)
+	threads := make([]string, 0)
+	for _, message := range c.messages {
+		if message.Channel.ID != channelID || seen[message.ThreadTimestamp] {
+			continue
+		}
+		if !message.IsReply && message.ReplyCount == 0 {
+			continue
+		}
+		if message.Time.Before(since) && message.LastReply.Before(since) {
+			continue
+		}
+		seen[message.ThreadTimestamp] = true
+		threads = append(threads, message.ThreadTimestamp)
+	}
+	return threads
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// EventType is the kind of an Event.
+type EventType int
+
+// Kinds of events sent by an EventSource.
+const (
+	// EventConnected is sent once the connection is up, the first time and
+	// after every reconnection.
+	EventConnected EventType = iota
+	// EventDisconnected is sent when the connection is lost, the source
+	// then reconnects on its own.
+	EventDisconnected
+	// EventConnectionError is sent when a connection attempt failed.
+	EventConnectionError
+	// EventMessage is sent for every message posted, edited or deleted.
+	EventMessage
+	// EventReactionAdded and EventReactionRemoved are sent when a user
+	// reacts to a message, or removes the reaction.
+	EventReactionAdded
+	EventReactionRemoved
+	// EventError is sent for the errors that do not affect the connection.
+	EventError
+	// EventInvalidAuth is sent when the token is rejected, no event follows.
+	EventInvalidAuth
+)
+
+// Event is an event received from Slack, whatever the transport.
+type Event struct {
+	Type EventType
+	// Message is set for EventMessage.
+	Message *slack.MessageEvent
+	// Blocks are the blocks of the message, or of its new version when it
+	// was edited, when the transport provides them.
+	Blocks []Block
+	// Reaction is set for EventReactionAdded and EventReactionRemoved.
+	Reaction *slack.ReactionAddedEvent
+	// Attempt is the number of the connection attempt, for
+	// EventConnectionError.
+	Attempt int
+	// Err is set for EventConnectionError and EventError.
+	Err error
+}
+
+// EventSource receives the events of a workspace over a transport, e.g. RTM
+// or Socket Mode. The events channel is closed once the source is closed.
+type EventSource interface {
+	Events() <-chan Event
+	Close() error
+}
+
+// rtmSource receives the events over the legacy Real Time Messaging API.
+type rtmSource struct {
+	rtm    *slack.RTM
+	events chan Event
+}
+
+// NewRTMSource connects to the RTM API with the token of the client, and
+// reconnects whenever the connection is lost.
+func NewRTMSource(client *slack.Client) EventSource {
+	r := &rtmSource{
+		rtm:    client.NewRTM(),
+		events: make(chan Event),
+	}
+	go r.rtm.ManageConnection()
+	go r.run()
+	return r
+}
+
+func (r *rtmSource) Events() <-chan Event {
+	return r.events
+}
+
+func (r *rtmSource) Close() error {
+	return r.rtm.Disconnect()
+}
+
+func (r *rtmSource) run() {
+	defer close(r.events)
+	for msg := range r.rtm.IncomingEvents {
+		switch ev := msg.Data.(type) {
+		case *slack.ConnectedEvent:
+			r.events <- Event{Type: EventConnected}
+
+		case *slack.DisconnectedEvent:
+			if ev.Intentional {
+				return
+			}
+			r.events <- Event{Type: EventDisconnected}
+
+		case *slack.ConnectionErrorEvent:
+			r.events <- Event{Type: EventConnectionError, Attempt: ev.Attempt, Err: ev}
+
+		case *slack.MessageEvent:
+			r.events <- Event{Type: EventMessage, Message: ev}
+
+		case *slack.ReactionAddedEvent:
+			r.events <- Event{Type: EventReactionAdded, Reaction: ev}
+
+		case *slack.ReactionRemovedEvent:
+			reaction := slack.ReactionAddedEvent(*ev)
+			r.events <- Event{Type: EventReactionRemoved, Reaction: &reaction}
+
+		case *slack.RTMError:
+			r.events <- Event{Type: EventError, Err: ev}
+
+		case *slack.InvalidAuthEvent:
+			r.events <- Event{Type: EventInvalidAuth}
+			return
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// mentionRegex matches the user (<@U123>), special (<!here>) and user group
+// (<!subteam^S123|@oncall>) mentions in the raw message text.
+var mentionRegex = regexp.MustCompile(`<([@!])([^>|]+)(\|[^>]*)?>`)
+
+// IsMention returns true when the raw message text mentions the current user,
+// directly, with <!here>, <!channel> or <!everyone>, or through one of their
+// user groups.
+func (s *SlackService) IsMention(text string) bool {
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		id := match[2]
+		if match[1] == "@" {
+			if id == s.CurrentUserID {
+				return true
+			}
+			continue
+		}
+		switch {
+		case id == "here" || id == "channel" || id == "everyone":
+			return true
+		case strings.HasPrefix(id, "subteam^"):
+			group, ok := s.getUserGroup(strings.TrimPrefix(id, "subteam^"))
+			if ok && contains(group.Users, s.CurrentUserID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entityRegex matches the user (<@U123>), channel (<#C123|general>) and
+// special (<!here>, <!subteam^S123|@oncall>, <!date^1392734382^{date}|...>)
+// tokens in the raw message text, with their optional label.
+//
+// https://api.slack.com/reference/surfaces/formatting#retrieving-messages
+var entityRegex = regexp.MustCompile(`<([@#!])([^>|]+)(?:\|([^>]*))?>`)
+
+// parseEntities replaces the user, channel and special tokens of the message
+// text with their readable form:
+//
+//	<@U12345|erroneousboat>, <@U12345>   @erroneousboat
+//	<#C12345|general>, <#C12345>         #general
+//	<!subteam^S12345|@oncall>            @oncall
+//	<!here>, <!channel>, <!everyone>     @here, @channel, @everyone
+//	<!date^1392734382^{date_short}|...>  Feb 18, 2014, in the local time zone
+//
+// The links are left alone.
+func parseEntities(s *SlackService, msg string) string {
+	return entityRegex.ReplaceAllStringFunc(msg, func(token string) string {
+		match := entityRegex.FindStringSubmatch(token)
+		id, label := match[2], match[3]
+		switch match[1] {
+		case "@":
+			return "@" + s.userName(id)
+		case "#":
+			if label != "" {
+				return "#" + label
+			}
+			if name, ok := s.channelName(id); ok {
+				return "#" + name
+			}
+			return "#" + id
+		}
+
+		switch {
+		case strings.HasPrefix(id, "subteam^"):
+			if label != "" {
+				return label
+			}
+			if group, ok := s.getUserGroup(strings.TrimPrefix(id, "subteam^")); ok && group.Handle != "" {
+				return "@" + group.Handle
+			}
+			return "@subteam"
+		case strings.HasPrefix(id, "date^"):
+			date, ok := formatDateToken(id, time.Now())
+			if !ok {
+				return label
+			}
+			return date
+		case label != "":
+			return label
+		default:
+			// e.g. here, channel and everyone.
+			return "@" + id
+		}
+	})
+}
+
+// userName returns the name of a user, fetched when not cached yet.
+func (s *SlackService) userName(userID string) string {
+	name, ok := s.getCachedUser(userID)
+	if !ok {
+		user, err := s.getUserInfo(userID)
+		if err != nil {
+			name = "unknown"
+		} else {
+			name = user.Name
+		}
+		s.setCachedUser(userID, name)
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	return name
+}
+
+// channelName returns the name of a channel listed by GetChannels.
+func (s *SlackService) channelName(channelID string) (string, bool) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	channel, ok := s.Channels[channelID]
+	return channel.Name, ok
+}
+
+// dateFormatRegex matches the tokens of a date format, e.g. '{date_short}'.
+var dateFormatRegex = regexp.MustCompile(`\{\w+\}`)
+
+// formatDateToken formats the date of a token, e.g.
+// 'date^1392734382^{date_short} at {time}^https://example.com', in the local
+// time zone. The days close to now are 'today', 'yesterday' or 'tomorrow'
+// with the '_pretty' formats.
+//
+// https://api.slack.com/reference/surfaces/formatting#date-formatting
+func formatDateToken(token string, now time.Time) (string, bool) {
+	parts := strings.Split(token, "^")
+	if len(parts) < 3 {
+		return "", false
+	}
+	seconds, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	t := time.Unix(seconds, 0).Local()
+	now = now.Local()
+
+	day := func(format string) string {
+		return fmt.Sprintf(t.Format(format), ordinal(t.Day()))
+	}
+	relative := func(absolute func() string) string {
+		switch t.Format("2006-01-02") {
+		case now.Format("2006-01-02"):
+			return "today"
+		case now.AddDate(0, 0, -1).Format("2006-01-02"):
+			return "yesterday"
+		case now.AddDate(0, 0, 1).Format("2006-01-02"):
+			return "tomorrow"
+		}
+		return absolute()
+	}
+	formats := map[string]func() string{
+		"date_num":          func() string { return t.Format("2006-01-02") },
+		"date":              func() string { return day("January %s, 2006") },
+		"date_short":        func() string { return t.Format("Jan 2, 2006") },
+		"date_long":         func() string { return day("Monday, January %s, 2006") },
+		"date_pretty":       func() string { return relative(func() string { return day("January %s, 2006") }) },
+		"date_short_pretty": func() string { return relative(func() string { return t.Format("Jan 2, 2006") }) },
+		"date_long_pretty":  func() string { return relative(func() string { return day("Monday, January %s, 2006") }) },
+		"time":              func() string { return t.Format("3:04 PM") },
+		"time_secs":         func() string { return t.Format("3:04:05 PM") },
+	}
+
+	valid := true
+	date := dateFormatRegex.ReplaceAllStringFunc(parts[2], func(token string) string {
+		format, ok := formats[strings.Trim(token, "{}")]
+		if !ok {
+			valid = false
+			return token
+		}
+		return format()
+	})
+	return date, valid
+}
+
+// ordinal returns the day of the month with its suffix, e.g. '1st' or '22nd'.
+func ordinal(day int) string {
+	suffix := "th"
+	switch {
+	case day%100 >= 11 && day%100 <= 13:
+	case day%10 == 1:
+		suffix = "st"
+	case day%10 == 2:
+		suffix = "nd"
+	case day%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(day) + suffix
+}
+
+// getUserGroup returns a user group of the workspace. The user groups are
+// fetched once, on the first call.
+func (s *SlackService) getUserGroup(ID string) (slack.UserGroup, bool) {
+	s.userGroupsOnce.Do(func() {
+		userGroups := make(map[string]slack.UserGroup)
+		// Not available on every plan, no user group is found in that case.
+		var groups []slack.UserGroup
+		err := s.limiter.Do("usergroups.list", func() (err error) {
+			groups, err = s.Client.GetUserGroups(slack.GetUserGroupsOptionIncludeUsers(true))
+			return err
+		})
+		if err == nil {
+			for _, group := range groups {
+				userGroups[group.ID] = group
+			}
+		}
+		s.mutex.Lock()
+		s.userGroups = userGroups
+		s.mutex.Unlock()
+	})
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	group, ok := s.userGroups[ID]
+	return group, ok
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/j-martin/slag/components"
+)
+
+// entities decodes the characters escaped by Slack in the message text.
+//
+// https://api.slack.com/reference/surfaces/formatting#escaping
+var entities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// listItem matches the items of bulleted and numbered lists, e.g. '• item'
+// or '1. item'.
+var listItem = regexp.MustCompile(`^(\s*(?:[•◦▪-]|\d+\.))\s+(.*)$`)
+
+// parseMrkdwn parses the text of a message, in the mrkdwn format of Slack,
+// into nodes. Unbalanced markers are kept as text.
+//
+// https://api.slack.com/reference/surfaces/formatting#basics
+func parseMrkdwn(text string) []components.Node {
+	// The code blocks come first, nothing is parsed inside them.
+	segments := strings.Split(text, "```")
+	if len(segments)%2 == 0 {
+		last := len(segments) - 1
+		segments[last-1] += "```" + segments[last]
+		segments = segments[:last]
+	}
+
+	nodes := make([]components.Node, 0)
+	for i, segment := range segments {
+		if i%2 == 0 {
+			nodes = append(nodes, parseLines(segment)...)
+			continue
+		}
+		// The code blocks stand on their own lines.
+		if len(nodes) > 0 && !strings.HasSuffix(segments[i-1], "\n") {
+			nodes = append(nodes, textNode("\n"))
+		}
+		code := strings.TrimSuffix(strings.TrimPrefix(segment, "\n"), "\n")
+		nodes = append(nodes, components.Node{Type: components.NodeCodeBlock, Text: entities.Replace(code)})
+		if next := segments[i+1]; next != "" && !strings.HasPrefix(next, "\n") {
+			nodes = append(nodes, textNode("\n"))
+		}
+	}
+	return nodes
+}
+
+// parseLines parses the quotes, the list items and the inline styles of the
+// lines.
+func parseLines(text string) []components.Node {
+	if text == "" {
+		return nil
+	}
+	// The consecutive quoted lines are grouped in a single quote, '>>>'
+	// quotes every line after it.
+	type block struct {
+		quote bool
+		lines []string
+	}
+	blocks := make([]block, 0)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if rest, ok := cutQuote(line, "&gt;&gt;&gt;"); ok {
+			blocks = append(blocks, block{true, append([]string{rest}, lines[i+1:]...)})
+			break
+		}
+		if rest, ok := cutQuote(line, "&gt;"); ok {
+			if n := len(blocks); n > 0 && blocks[n-1].quote {
+				blocks[n-1].lines = append(blocks[n-1].lines, rest)
+			} else {
+				blocks = append(blocks, block{true, []string{rest}})
+			}
+			continue
+		}
+		blocks = append(blocks, block{false, []string{line}})
+	}
+
+	nodes := make([]components.Node, 0)
+	for i, b := range blocks {
+		if i > 0 {
+			nodes = append(nodes, textNode("\n"))
+		}
+		if b.quote {
+			nodes = append(nodes, components.Node{
+				Type:     components.NodeQuote,
+				Children: parseLines(strings.Join(b.lines, "\n")),
+			})
+			continue
+		}
+		if match := listItem.FindStringSubmatch(b.lines[0]); match != nil {
+			nodes = append(nodes, components.Node{
+				Type:     components.NodeListItem,
+				Text:     match[1],
+				Children: parseInline(match[2]),
+			})
+			continue
+		}
+		nodes = append(nodes, parseInline(b.lines[0])...)
+	}
+	return nodes
+}
+
+// cutQuote returns the line without the quote marker and the space following
+// it, if the line starts with the marker.
+func cutQuote(line string, marker string) (string, bool) {
+	if !strings.HasPrefix(line, marker) {
+		return line, false
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(line, marker), " "), true
+}
+
+// styles are the inline style markers.
+var styles = map[byte]components.NodeType{
+	'*': components.NodeBold,
+	'_': components.NodeItalic,
+	'~': components.NodeStrike,
+}
+
+// parseInline parses the inline code, styles and links of a line. A style
+// marker only opens at the start of a word and closes at the end of one, so
+// that e.g. 'snake_case' or '2*3*4' are left alone. The other Slack tokens,
+// e.g. mentions, must be resolved first, see parseEntities.
+func parseInline(line string) []components.Node {
+	nodes := make([]components.Node, 0)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			nodes = append(nodes, textNode(entities.Replace(line[start:end])))
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		style, isStyle := styles[c]
+		switch {
+		case c == '`':
+			end := strings.IndexByte(line[i+1:], '`')
+			if end <= 0 {
+				continue
+			}
+			flush(i)
+			nodes = append(nodes, components.Node{
+				Type: components.NodeCode,
+				Text: entities.Replace(line[i+1 : i+1+end]),
+			})
+			i += end + 1
+			start = i + 1
+
+		case c == '<':
+			end := strings.IndexByte(line[i:], '>')
+			if end < 0 {
+				continue
+			}
+			flush(i)
+			nodes = append(nodes, parseLink(line[i+1:i+end]))
+			i += end
+			start = i + 1
+
+		case isStyle:
+			end := closingMarker(line, i)
+			if end < 0 {
+				continue
+			}
+			flush(i)
+			nodes = append(nodes, components.Node{
+				Type:     style,
+				Children: parseInline(line[i+1 : end]),
+			})
+			i = end
+			start = i + 1
+		}
+	}
+	flush(len(line))
+	return nodes
+}
+
+// parseLink parses the content of a link token, e.g.
+// 'https://example.com|example' for '<https://example.com|example>'.
+func parseLink(token string) components.Node {
+	link := components.Node{Type: components.NodeLink}
+	parts := strings.SplitN(token, "|", 2)
+	link.Text = entities.Replace(parts[0])
+	if len(parts) == 2 && parts[1] != "" {
+		link.Children = []components.Node{textNode(entities.Replace(parts[1]))}
+	}
+	return link
+}
+
+// closingMarker returns the index of the marker closing the one at start, or
+// -1 when the marker does not open a style.
+func closingMarker(line string, start int) int {
+	marker := line[start]
+	if start > 0 {
+		if previous, _ := utf8.DecodeLastRuneInString(line[:start]); isWordRune(previous) {
+			return -1
+		}
+	}
+	if start+1 >= len(line) || line[start+1] == ' ' || line[start+1] == marker {
+		return -1
+	}
+	for end := start + 2; end < len(line); end++ {
+		if line[end] != marker || line[end-1] == ' ' {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(line[end+1:]); end+1 < len(line) && isWordRune(next) {
+			continue
+		}
+		return end
+	}
+	return -1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func textNode(s string) components.Node {
+	return components.Node{Type: components.NodeText, Text: s}
+}
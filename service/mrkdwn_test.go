@@ -0,0 +1,107 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+)
+
+func TestParseMrkdwn(t *testing.T) {
+	text := textNode
+	styled := func(nodeType components.NodeType, children ...components.Node) components.Node {
+		return components.Node{Type: nodeType, Children: children}
+	}
+	tests := []struct {
+		input    string
+		expected []components.Node
+		plain    string
+	}{
+		{
+			"*bold* _italic_ ~strike~",
+			[]components.Node{
+				styled(components.NodeBold, text("bold")), text(" "),
+				styled(components.NodeItalic, text("italic")), text(" "),
+				styled(components.NodeStrike, text("strike")),
+			},
+			"bold italic strike",
+		},
+		{
+			"*bold _and italic_*",
+			[]components.Node{
+				styled(components.NodeBold, text("bold "), styled(components.NodeItalic, text("and italic"))),
+			},
+			"bold and italic",
+		},
+		{
+			"snake_case_name, 2*3*4 and * not bold*",
+			[]components.Node{text("snake_case_name, 2*3*4 and * not bold*")},
+			"snake_case_name, 2*3*4 and * not bold*",
+		},
+		{
+			"run `make *all*` &amp; wait",
+			[]components.Node{
+				text("run "), {Type: components.NodeCode, Text: "make *all*"}, text(" & wait"),
+			},
+			"run make *all* & wait",
+		},
+		{
+			"see:```if a &lt; b {\n  _x_\n}```done",
+			[]components.Node{
+				text("see:"), text("\n"), {Type: components.NodeCodeBlock, Text: "if a < b {\n  _x_\n}"}, text("\n"), text("done"),
+			},
+			"see:\nif a < b {\n  _x_\n}\ndone",
+		},
+		{
+			"&gt; quoted *line*\n&gt; second\nreply",
+			[]components.Node{
+				styled(components.NodeQuote, text("quoted "), styled(components.NodeBold, text("line")), text("\n"), text("second")),
+				text("\n"), text("reply"),
+			},
+			"> quoted line\n> second\nreply",
+		},
+		{
+			"&gt;&gt;&gt;all\nquoted",
+			[]components.Node{
+				styled(components.NodeQuote, text("all"), text("\n"), text("quoted")),
+			},
+			"> all\n> quoted",
+		},
+		{
+			"• first\n2. _second_",
+			[]components.Node{
+				{Type: components.NodeListItem, Text: "•", Children: []components.Node{text("first")}},
+				text("\n"),
+				{Type: components.NodeListItem, Text: "2.", Children: []components.Node{styled(components.NodeItalic, text("second"))}},
+			},
+			"• first\n2. second",
+		},
+		{
+			"<https://example.com/a_b_c|link> ``` unbalanced",
+			[]components.Node{
+				{Type: components.NodeLink, Text: "https://example.com/a_b_c", Children: []components.Node{text("link")}},
+				text(" ``` unbalanced"),
+			},
+			"link (https://example.com/a_b_c) ``` unbalanced",
+		},
+		{
+			"see <https://example.com?a=1&amp;b=2> or <mailto:jane@example.com|jane@example.com>",
+			[]components.Node{
+				text("see "),
+				{Type: components.NodeLink, Text: "https://example.com?a=1&b=2"},
+				text(" or "),
+				{Type: components.NodeLink, Text: "mailto:jane@example.com", Children: []components.Node{text("jane@example.com")}},
+			},
+			"see https://example.com?a=1&b=2 or jane@example.com",
+		},
+	}
+	for _, test := range tests {
+		nodes := parseMrkdwn(test.input)
+		if !reflect.DeepEqual(nodes, test.expected) {
+			t.Errorf("%q: %+v not equal to %+v", test.input, nodes, test.expected)
+		}
+		if plain := components.PlainText(nodes); plain != test.plain {
+			t.Errorf("%q: %q not equal to %q", test.input, plain, test.plain)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/j-martin/slag/components"
+)
+
+// parentResolver renders the live messages with the parent of the replies.
+// The parents missing from the cache are fetched in the background, so that
+// a burst of replies in old threads does not hold the other events back. The
+// replies of a thread wait for its parent, to be rendered in order.
+type parentResolver struct {
+	s    *SlackService
+	sink Sink
+	// pending are the replies waiting for the parent of their thread, by
+	// channel and thread timestamp.
+	pending map[string][]components.Message
+	mutex   sync.Mutex
+	// errs receives the first error of the sink in the background.
+	errs chan error
+}
+
+func newParentResolver(s *SlackService, sink Sink) *parentResolver {
+	return &parentResolver{
+		s:       s,
+		sink:    sink,
+		pending: make(map[string][]components.Message),
+		errs:    make(chan error, 1),
+	}
+}
+
+// Message renders the message, right away unless it is a reply whose parent
+// must be fetched first. The parents are only needed for the threaded view.
+func (p *parentResolver) Message(message components.Message) error {
+	if !message.IsReply || !p.s.Threaded {
+		return p.sink.Message(message)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := cacheKey(message.Channel.ID, message.ThreadTimestamp)
+	if replies, ok := p.pending[key]; ok {
+		p.pending[key] = append(replies, message)
+		return nil
+	}
+	if parent, ok := p.s.cache.Get(message.Channel.ID, message.ThreadTimestamp); ok {
+		message.Parent = &parent
+		return p.sink.Message(message)
+	}
+	p.pending[key] = []components.Message{message}
+	go p.resolve(message.Channel, message.ThreadTimestamp, key)
+	return nil
+}
+
+// resolve fetches the parent of the thread, then renders its pending
+// replies, without the parent when it cannot be fetched.
+func (p *parentResolver) resolve(channel *components.Channel, threadTimestamp string, key string) {
+	parent := p.s.fetchParent(channel, threadTimestamp)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, reply := range p.pending[key] {
+		reply.Parent = parent
+		if err := p.sink.Message(reply); err != nil {
+			select {
+			case p.errs <- err:
+			default:
+			}
+			break
+		}
+	}
+	delete(p.pending, key)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/j-martin/slag/components"
+)
+
+// recorder renders the content of the messages, with their parent.
+type recorder struct {
+	mutex    sync.Mutex
+	rendered []string
+	done     chan bool
+}
+
+func (r *recorder) Start() error { return nil }
+func (r *recorder) Message(message components.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	content := message.Content
+	if message.Parent != nil {
+		content += " (re " + message.Parent.Content + ")"
+	}
+	r.rendered = append(r.rendered, content)
+	if len(r.rendered) == 4 {
+		close(r.done)
+	}
+	return nil
+}
+func (r *recorder) Edit(edit components.Edit) error             { return nil }
+func (r *recorder) Delete(deletion components.Deletion) error   { return nil }
+func (r *recorder) Gap(gap components.Gap) error                { return nil }
+func (r *recorder) Reaction(reaction components.Reaction) error { return nil }
+func (r *recorder) End() error                                  { return nil }
+
+func TestParentResolver(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, `{"ok":true,"messages":[{"user":"U1","text":"parent","ts":"1.000100","thread_ts":"1.000100"}]}`)
+	}))
+	defer server.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = server.URL + "/"
+
+	s := &SlackService{
+		Client:    slack.New("token"),
+		UserCache: map[string]string{"U1": "jane"},
+		mutex:     &sync.Mutex{},
+		limiter:   NewLimiter(),
+		cache:     newMessageCache(messageCacheCapacity),
+		blocks:    newBlockStore(messageCacheCapacity),
+		Threaded:  true,
+	}
+	r := &recorder{done: make(chan bool)}
+	p := newParentResolver(s, r)
+	channel := &components.Channel{ID: "C1"}
+	reply := func(content string, timestamp string) components.Message {
+		return components.Message{Channel: channel, Content: content, Timestamp: timestamp, ThreadTimestamp: "1.000100", IsReply: true}
+	}
+	p.Message(reply("first", "2.000100"))
+	p.Message(reply("second", "3.000100"))
+	p.Message(components.Message{Channel: channel, Content: "unrelated", Timestamp: "4.000100", ThreadTimestamp: "4.000100"})
+	// The unrelated message is not held back by the parent being fetched.
+	r.mutex.Lock()
+	if fmt.Sprint(r.rendered) != "[unrelated]" {
+		t.Errorf("unexpected messages rendered while fetching: %q", r.rendered)
+	}
+	r.mutex.Unlock()
+	close(release)
+
+	p.Message(reply("third", "5.000100"))
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the replies were not rendered")
+	}
+	expected := "[unrelated first (re parent) second (re parent) third (re parent)]"
+	if fmt.Sprint(r.rendered) != expected {
+		t.Errorf("%q not equal to %q", fmt.Sprint(r.rendered), expected)
+	}
+}
+
+func TestParentResolverUnthreaded(t *testing.T) {
+	s := &SlackService{cache: newMessageCache(messageCacheCapacity)}
+	r := &recorder{done: make(chan bool)}
+	p := newParentResolver(s, r)
+	// The parent is not fetched, the client would fail without a server.
+	p.Message(components.Message{Channel: &components.Channel{ID: "C1"}, Content: "reply", Timestamp: "2.000100", ThreadTimestamp: "1.000100", IsReply: true})
+	if fmt.Sprint(r.rendered) != "[reply]" {
+		t.Errorf("expected the reply to be rendered right away, got %q", r.rendered)
+	}
+}
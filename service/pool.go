@@ -0,0 +1,28 @@
+package service
+
+import "sync"
+
+// ForEach calls fn with every index below n, with at most concurrency calls
+// at once, and returns once they all returned. It bounds the requests sent in
+// parallel, e.g. to fetch the history of many channels.
+func ForEach(n int, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	var mutex sync.Mutex
+	running, peak := 0, 0
+	seen := make([]bool, 10)
+	ForEach(len(seen), 3, func(i int) {
+		mutex.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		seen[i] = true
+		mutex.Unlock()
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	})
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("index %d skipped", i)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("%d calls at once, expected at most 3", peak)
+	}
+}
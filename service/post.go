@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/j-martin/slag/components"
+)
+
+// ResolveChannel finds the conversation designated by name among the ones
+// returned by GetChannels:
+//
+//	#general, general   a channel, group or multi-party IM
+//	@erroneousboat      the direct messages with a user, opened if needed
+//	C12345              a conversation ID
+func (s *SlackService) ResolveChannel(name string) (components.Channel, error) {
+	channels, err := s.GetChannels()
+	if err != nil {
+		return components.Channel{}, err
+	}
+
+	if strings.HasPrefix(name, "@") {
+		username := strings.TrimPrefix(name, "@")
+		for _, channel := range channels {
+			if channel.Type == components.ChannelTypeIM && channel.Name == username {
+				return channel, nil
+			}
+		}
+		userID, ok := s.getCachedUserID(username)
+		if !ok {
+			return components.Channel{}, fmt.Errorf("unknown user: '%s'", name)
+		}
+		var channelID string
+		err := s.limiter.Do("conversations.open", func() (err error) {
+			_, _, channelID, err = s.Client.OpenIMChannel(userID)
+			return err
+		})
+		if err != nil {
+			return components.Channel{}, err
+		}
+		return components.Channel{
+			ID:        channelID,
+			Workspace: s.CurrentTeamInfo.Domain,
+			Name:      username,
+			Type:      components.ChannelTypeIM,
+			UserID:    userID,
+		}, nil
+	}
+
+	channelName := strings.TrimPrefix(name, "#")
+	for _, channel := range channels {
+		if channel.Type != components.ChannelTypeIM && channel.Name == channelName {
+			return channel, nil
+		}
+	}
+	for _, channel := range channels {
+		if channel.ID == name {
+			return channel, nil
+		}
+	}
+	return components.Channel{}, fmt.Errorf("unknown channel: '%s'", name)
+}
+
+// PostMessage sends text to the channel as the current user. When
+// threadTimestamp is set, the message is a reply in that thread. It returns
+// the timestamp of the new message.
+func (s *SlackService) PostMessage(channelID string, text string, threadTimestamp string) (string, error) {
+	options := []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAsUser(true),
+	}
+	if threadTimestamp != "" {
+		options = append(options, slack.MsgOptionTS(threadTimestamp))
+	}
+	var timestamp string
+	err := s.limiter.Do("chat.postMessage", func() (err error) {
+		_, timestamp, err = s.Client.PostMessage(channelID, options...)
+		return err
+	})
+	return timestamp, err
+}
+
+func (s *SlackService) getCachedUserID(username string) (string, bool) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	for id, name := range s.UserCache {
+		if name == username {
+			return id, true
+		}
+	}
+	return "", false
+}
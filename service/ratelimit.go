@@ -0,0 +1,230 @@
+package service
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Tier is the rate limit of a Slack API method.
+//
+// https://api.slack.com/docs/rate-limits
+type Tier struct {
+	// PerMinute is the sustained number of calls per minute.
+	PerMinute int
+	// Burst is the number of calls allowed at once.
+	Burst int
+}
+
+// The Slack rate limit tiers.
+var (
+	Tier1 = Tier{PerMinute: 1, Burst: 1}
+	Tier2 = Tier{PerMinute: 20, Burst: 20}
+	Tier3 = Tier{PerMinute: 50, Burst: 50}
+	Tier4 = Tier{PerMinute: 100, Burst: 100}
+)
+
+// Tiers are the rate limits of the Slack API methods used by the service.
+// The methods not listed use Tier3.
+var Tiers = map[string]Tier{
+	"auth.test":             Tier4,
+	"chat.postMessage":      {PerMinute: 60, Burst: 5},
+	"conversations.history": Tier3,
+	"conversations.list":    Tier2,
+	"conversations.mark":    Tier3,
+	"conversations.open":    Tier3,
+	"conversations.replies": Tier3,
+	"team.info":             Tier3,
+	"usergroups.list":       Tier2,
+	"users.getPresence":     Tier3,
+	"users.info":            Tier4,
+	"users.list":            Tier2,
+}
+
+// Errors returned by the Slack API that are worth retrying.
+var transientErrors = map[string]bool{
+	"internal_error":      true,
+	"fatal_error":         true,
+	"request_timeout":     true,
+	"service_unavailable": true,
+}
+
+// unsafeMethods are the methods whose calls may have taken effect although
+// their response was lost, e.g. a message posted twice if retried. They are
+// only retried when rate limited, Slack then refused the call.
+var unsafeMethods = map[string]bool{
+	"chat.postMessage": true,
+}
+
+// Limiter throttles the calls to the Slack API with a token bucket per
+// method, and retries the calls that were rate limited or that failed
+// transiently.
+type Limiter struct {
+	// MaxRetries is the number of retries of a call before giving up.
+	MaxRetries int
+	// Backoff is the delay before the first retry of a transient error, it
+	// doubles on every retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	buckets map[string]*bucket
+	mutex   sync.Mutex
+
+	// Replaced by the tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewLimiter creates a limiter with the rate limits of Tiers.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		MaxRetries: 5,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+		buckets:    make(map[string]*bucket),
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+}
+
+// Do calls the Slack API method once the rate limit allows it. Rate limited
+// calls are retried after the delay requested by Slack, which also applies
+// to the other calls of the method, and the transient errors are retried
+// with a jittered exponential backoff, except for the unsafeMethods.
+func (l *Limiter) Do(method string, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		l.sleep(l.reserve(method))
+		err := call()
+		if err == nil {
+			return nil
+		}
+		if attempt >= l.MaxRetries {
+			return err
+		}
+		if rateLimited, ok := err.(*slack.RateLimitedError); ok {
+			log.Printf("%s: rate limited, retrying in %s", method, rateLimited.RetryAfter)
+			l.block(method, rateLimited.RetryAfter)
+			continue
+		}
+		if !isTransient(err) || unsafeMethods[method] {
+			return err
+		}
+		delay := l.backoff(attempt)
+		log.Printf("%s: %s, retrying in %s", method, err, delay)
+		l.sleep(delay)
+	}
+}
+
+// Allow takes a token for the method if one is available right away, e.g.
+// for best-effort calls that should not delay the others.
+func (l *Limiter) Allow(method string) bool {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+	return l.bucket(method).take(l.now())
+}
+
+// reserve takes a token for the method and returns how long to wait before
+// using it.
+func (l *Limiter) reserve(method string) time.Duration {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+	return l.bucket(method).reserve(l.now())
+}
+
+// block delays the calls to the method, after Slack rate limited it.
+func (l *Limiter) block(method string, delay time.Duration) {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+	b := l.bucket(method)
+	if until := l.now().Add(delay); until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// backoff returns the delay before the retry, with jitter so that the
+// concurrent calls do not retry all at once.
+func (l *Limiter) backoff(attempt int) time.Duration {
+	delay := l.Backoff << uint(attempt)
+	if delay <= 0 || delay > l.MaxBackoff {
+		delay = l.MaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// bucket returns the bucket of the method. The mutex must be held.
+func (l *Limiter) bucket(method string) *bucket {
+	b, ok := l.buckets[method]
+	if !ok {
+		tier, ok := Tiers[method]
+		if !ok {
+			tier = Tier3
+		}
+		b = &bucket{
+			rate:     float64(tier.PerMinute) / 60,
+			capacity: float64(tier.Burst),
+			tokens:   float64(tier.Burst),
+			last:     l.now(),
+		}
+		l.buckets[method] = b
+	}
+	return b
+}
+
+// isTransient returns whether the error is likely to go away on retry.
+func isTransient(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	if statusErr, ok := err.(interface{ HTTPStatusCode() int }); ok {
+		return statusErr.HTTPStatusCode() >= 500
+	}
+	return transientErrors[err.Error()]
+}
+
+// bucket is a token bucket. The tokens can go negative, the callers then
+// queue up behind the ones already waiting.
+type bucket struct {
+	rate         float64 // tokens per second
+	capacity     float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > delay {
+		delay = blocked
+	}
+	return delay
+}
+
+func (b *bucket) take(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 || now.Before(b.blockedUntil) {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// newTestLimiter returns a limiter with a fake clock, advanced by sleep.
+func newTestLimiter() (*Limiter, *time.Duration) {
+	l := NewLimiter()
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	slept := new(time.Duration)
+	l.now = func() time.Time {
+		return now.Add(*slept)
+	}
+	l.sleep = func(d time.Duration) {
+		*slept += d
+	}
+	return l, slept
+}
+
+func TestLimiterBucket(t *testing.T) {
+	l, slept := newTestLimiter()
+	call := func() error { return nil }
+	for i := 0; i < Tier2.Burst; i++ {
+		l.Do("conversations.list", call)
+	}
+	if *slept != 0 {
+		t.Errorf("the burst should not wait, waited %s", *slept)
+	}
+	l.Do("conversations.list", call)
+	if *slept != 3*time.Second {
+		t.Errorf("expected to wait 3s after the burst, waited %s", *slept)
+	}
+	if !l.Allow("users.info") {
+		t.Error("other methods should not be limited")
+	}
+}
+
+func TestLimiterRetries(t *testing.T) {
+	l, slept := newTestLimiter()
+	calls := 0
+	err := l.Do("conversations.history", func() error {
+		calls++
+		if calls == 1 {
+			return &slack.RateLimitedError{RetryAfter: 30 * time.Second}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected a retry after the rate limit, got %d calls and %v", calls, err)
+	}
+	if *slept != 30*time.Second {
+		t.Errorf("expected to wait for Retry-After, waited %s", *slept)
+	}
+
+	calls = 0
+	err = l.Do("conversations.history", func() error {
+		calls++
+		return errors.New("internal_error")
+	})
+	if err == nil || calls != l.MaxRetries+1 {
+		t.Errorf("expected %d calls for a transient error, got %d", l.MaxRetries+1, calls)
+	}
+
+	calls = 0
+	err = l.Do("conversations.history", func() error {
+		calls++
+		return errors.New("channel_not_found")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected no retry for a permanent error, got %d calls", calls)
+	}
+}
+
+// statusError is an HTTP error response, like the ones of the slack client.
+type statusError int
+
+func (e statusError) Error() string {
+	return "status error"
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return int(e)
+}
+
+func TestLimiterUnsafeRetries(t *testing.T) {
+	l, _ := newTestLimiter()
+	for _, failure := range []error{io.EOF, statusError(502), errors.New("internal_error")} {
+		calls := 0
+		err := l.Do("chat.postMessage", func() error {
+			calls++
+			return failure
+		})
+		if err != failure || calls != 1 {
+			t.Errorf("%v: expected the post not to be retried, got %d calls", failure, calls)
+		}
+	}
+
+	calls := 0
+	err := l.Do("chat.postMessage", func() error {
+		calls++
+		if calls == 1 {
+			return &slack.RateLimitedError{RetryAfter: time.Second}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected a rate limited post to be retried, got %d calls and %v", calls, err)
+	}
+}
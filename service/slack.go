@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"regexp"
 	"sort"
 	"strconv"
@@ -17,42 +18,88 @@ import (
 )
 
 type SlackService struct {
-	Client          *slack.Client
-	RTM             *slack.RTM
+	Client *slack.Client
+	// Events is nil for the clients created by NewSlackClient.
+	Events          EventSource
 	Conversations   []slack.Channel
 	UserCache       map[string]string
 	CurrentUserID   string
 	CurrentUsername string
 	CurrentTeamInfo *slack.TeamInfo
 	Channels        map[string]components.Channel
-	mutex           *sync.Mutex
+	// Concurrency is the number of channels fetched at once after a
+	// reconnection, one when unset.
+	Concurrency int
+	// Threaded shows the live replies with their parent, which is fetched
+	// when missing from the cache.
+	Threaded       bool
+	mutex          *sync.Mutex
+	userGroups     map[string]slack.UserGroup
+	userGroupsOnce sync.Once
+	limiter        *Limiter
+	cache          *messageCache
+	blocks         *blockStore
 }
 
 // NewSlackService is the constructor for the SlackService and will initialize
-// the RTM and a Client
-func NewSlackService(token string) (*SlackService, error) {
+// the Client and the event source: Socket Mode when the app-level token is
+// set, RTM otherwise.
+func NewSlackService(token string, appToken string) (*SlackService, error) {
+	return newSlackService(token, appToken, true)
+}
+
+// NewSlackClient initializes the SlackService without an event source, for
+// the commands only calling the Web API, e.g. to post a message.
+func NewSlackClient(token string) (*SlackService, error) {
+	return newSlackService(token, "", false)
+}
+
+func newSlackService(token string, appToken string, listen bool) (*SlackService, error) {
+	blocks := newBlockStore(messageCacheCapacity)
 	svc := &SlackService{
-		Client:    slack.New(token),
+		Client:    slack.New(token, slack.OptionHTTPClient(&blockRecorder{client: &http.Client{}, store: blocks})),
 		UserCache: make(map[string]string),
+		Channels:  make(map[string]components.Channel),
 		mutex:     &sync.Mutex{},
+		limiter:   NewLimiter(),
+		cache:     newMessageCache(messageCacheCapacity),
+		blocks:    blocks,
 	}
 
 	// Get user associated with token, mainly
 	// used to identify user when new messages
 	// arrives
-	authTest, err := svc.Client.AuthTest()
+	var authTest *slack.AuthTestResponse
+	err := svc.limiter.Do("auth.test", func() (err error) {
+		authTest, err = svc.Client.AuthTest()
+		return err
+	})
 	if err != nil {
 		return nil, errors.New("not able to authorize client, check your connection and if your slack-token is set correctly")
 	}
 	svc.CurrentUserID = authTest.UserID
 
-	// Create RTM
-	svc.RTM = svc.Client.NewRTM()
-	go svc.RTM.ManageConnection()
+	// Connect early so that no message is missed while fetching the
+	// history
+	switch {
+	case !listen:
+	case appToken != "":
+		svc.Events = NewSocketModeSource(appToken)
+	default:
+		svc.Events = NewRTMSource(svc.Client)
+	}
 
 	// Creation of user cache this speeds up
-	// the uncovering of usernames of messages
-	users, _ := svc.Client.GetUsers()
+	// the uncovering of usernames of messages. Without it, the users are
+	// fetched one by one as their messages arrive.
+	var users []slack.User
+	err = svc.limiter.Do("users.list", func() (err error) {
+		users, err = svc.Client.GetUsers()
+		return err
+	})
+	if err != nil {
+		log.Printf("failed to list the users: %s", err)
+	}
 	for _, user := range users {
 		// only add non-deleted users
 		if !user.Deleted {
@@ -62,67 +109,75 @@ func NewSlackService(token string) (*SlackService, error) {
 
 	teamInfo, err := svc.GetTeamInfo()
 	if err != nil {
+		if svc.Events != nil {
+			svc.Events.Close()
+		}
 		return nil, err
 	}
 	svc.CurrentTeamInfo = teamInfo
 	// Get name of current user
-	currentUser, err := svc.Client.GetUserInfo(svc.CurrentUserID)
+	currentUser, err := svc.getUserInfo(svc.CurrentUserID)
 	if err != nil {
 		svc.CurrentUsername = "slag"
+	} else {
+		svc.CurrentUsername = currentUser.Name
 	}
-	svc.CurrentUsername = currentUser.Name
 
 	return svc, nil
 }
 
 func (s *SlackService) GetTeamInfo() (*slack.TeamInfo, error) {
-	return s.Client.GetTeamInfo()
+	var teamInfo *slack.TeamInfo
+	err := s.limiter.Do("team.info", func() (err error) {
+		teamInfo, err = s.Client.GetTeamInfo()
+		return err
+	})
+	return teamInfo, err
+}
+
+// getUserInfo fetches the user from Slack, bypassing the cache.
+func (s *SlackService) getUserInfo(userID string) (*slack.User, error) {
+	var user *slack.User
+	err := s.limiter.Do("users.info", func() (err error) {
+		user, err = s.Client.GetUserInfo(userID)
+		return err
+	})
+	return user, err
 }
 
 func (s *SlackService) GetChannels() ([]components.Channel, error) {
 	slackChans := make([]slack.Channel, 0)
 
-	// Initial request
-	initChans, initCur, err := s.Client.GetConversations(
-		&slack.GetConversationsParameters{
-			ExcludeArchived: "true",
-			Limit:           1000,
-			Types: []string{
-				"public_channel",
-				"private_channel",
-				"im",
-				"mpim",
-			},
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	slackChans = append(slackChans, initChans...)
-
-	// Paginate over additional channels
-	nextCur := initCur
-	for nextCur != "" {
-		channels, cursor, err := s.Client.GetConversations(
-			&slack.GetConversationsParameters{
-				Cursor:          nextCur,
-				ExcludeArchived: "true",
-				Limit:           1000,
-				Types: []string{
-					"public_channel",
-					"private_channel",
-					"im",
-					"mpim",
+	// Paginate over the channels
+	cursor := ""
+	for {
+		var channels []slack.Channel
+		var next string
+		err := s.limiter.Do("conversations.list", func() (err error) {
+			channels, next, err = s.Client.GetConversations(
+				&slack.GetConversationsParameters{
+					Cursor:          cursor,
+					ExcludeArchived: "true",
+					Limit:           1000,
+					Types: []string{
+						"public_channel",
+						"private_channel",
+						"im",
+						"mpim",
+					},
 				},
-			},
-		)
+			)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
 
 		slackChans = append(slackChans, channels...)
-		nextCur = cursor
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
 	// We're creating tempChan, because we want to be able to
@@ -192,18 +247,24 @@ func (s *SlackService) GetChannels() ([]components.Channel, error) {
 				slackChannel: chn,
 			}
 
+			// The presence is best-effort, it is not worth delaying the
+			// startup when there are more IMs than the rate limit allows.
+			if !s.limiter.Allow("users.getPresence") {
+				buckets[3][chn.User].channelItem.Presence = "away"
+				continue
+			}
 			wg.Add(1)
-			go func(user string, buckets map[int]map[string]*tempChan) {
+			go func(item *tempChan) {
 				defer wg.Done()
 
-				presence, err := s.GetUserPresence(user)
+				presence, err := s.Client.GetUserPresence(item.channelItem.UserID)
 				if err != nil {
-					buckets[3][user].channelItem.Presence = "away"
+					item.channelItem.Presence = "away"
 					return
 				}
 
-				buckets[3][user].channelItem.Presence = presence
-			}(chn.User, buckets)
+				item.channelItem.Presence = presence.Presence
+			}(buckets[3][chn.User])
 		}
 	}
 
@@ -232,10 +293,13 @@ func (s *SlackService) GetChannels() ([]components.Channel, error) {
 		})
 
 		// Add Channel and SlackChannel to the SlackService struct
+		s.mutex.Lock()
 		for _, tc := range tcArr {
 			chans = append(chans, tc.channelItem)
 			s.Conversations = append(s.Conversations, tc.slackChannel)
+			s.Channels[tc.channelItem.ID] = tc.channelItem
 		}
+		s.mutex.Unlock()
 	}
 
 	return chans, nil
@@ -243,7 +307,11 @@ func (s *SlackService) GetChannels() ([]components.Channel, error) {
 
 // GetUserPresence will get the presence of a specific user
 func (s *SlackService) GetUserPresence(userID string) (string, error) {
-	presence, err := s.Client.GetUserPresence(userID)
+	var presence *slack.UserPresence
+	err := s.limiter.Do("users.getPresence", func() (err error) {
+		presence, err = s.Client.GetUserPresence(userID)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -253,38 +321,103 @@ func (s *SlackService) GetUserPresence(userID string) (string, error) {
 
 // MarkAsRead will set the channel as read
 func (s *SlackService) MarkAsRead(channelID string) {
-	s.Client.SetChannelReadMark(
-		channelID, fmt.Sprintf("%f",
-			float64(time.Now().Unix())),
-	)
+	s.limiter.Do("conversations.mark", func() error {
+		return s.Client.SetChannelReadMark(
+			channelID, fmt.Sprintf("%f",
+				float64(time.Now().Unix())),
+		)
+	})
+}
+
+// DefaultPageSize is the number of messages fetched per history request.
+// Slack recommends no more than 200.
+const DefaultPageSize = 200
+
+// HistoryQuery delimits the messages fetched by GetHistory.
+type HistoryQuery struct {
+	// Count is the maximum number of messages, the latest ones are kept.
+	// Zero means no limit.
+	Count int
+	// Oldest and Latest are the Slack timestamps bounding the messages,
+	// both excluded. Empty means no bound.
+	Oldest string
+	Latest string
+	// PageSize is the number of messages per request, DefaultPageSize when
+	// zero.
+	PageSize int
 }
 
 // GetMessages will get messages for a channel, group or im channel delimited
 // by a count.
 func (s *SlackService) GetMessages(channel components.Channel, count int) ([]components.Message, error) {
+	return s.GetHistory(channel, HistoryQuery{Count: count})
+}
 
-	// https://godoc.org/github.com/nlopes/slack#GetConversationHistoryParameters
-	historyParams := slack.GetConversationHistoryParameters{
-		ChannelID: channel.ID,
-		Limit:     count,
-		Inclusive: false,
-	}
+// GetMessagesSince will get all the messages of a channel, group or im
+// channel posted after the oldest timestamp.
+func (s *SlackService) GetMessagesSince(channel components.Channel, oldest string) ([]components.Message, error) {
+	return s.GetHistory(channel, HistoryQuery{Oldest: oldest})
+}
 
-	history, err := s.Client.GetConversationHistory(&historyParams)
-	if err != nil {
-		return nil, err
+// GetHistory will get the messages of a channel, group or im channel matching
+// the query, following the pagination until the count is reached or the
+// history is exhausted.
+func (s *SlackService) GetHistory(channel components.Channel, query HistoryQuery) ([]components.Message, error) {
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
 	}
 
-	// Construct the messages
 	var messages []components.Message
-	for _, message := range history.Messages {
-		msg, err := s.CreateMessage(message, &channel)
+	fetched := 0
+	cursor := ""
+	for {
+		limit := pageSize
+		if query.Count > 0 && query.Count-fetched < limit {
+			limit = query.Count - fetched
+		}
+
+		// https://godoc.org/github.com/nlopes/slack#GetConversationHistoryParameters
+		var history *slack.GetConversationHistoryResponse
+		err := s.limiter.Do("conversations.history", func() (err error) {
+			history, err = s.Client.GetConversationHistory(&slack.GetConversationHistoryParameters{
+				ChannelID: channel.ID,
+				Cursor:    cursor,
+				Oldest:    query.Oldest,
+				Latest:    query.Latest,
+				Limit:     limit,
+				Inclusive: false,
+			})
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, msg...)
+
+		// Construct the messages
+		for _, message := range history.Messages {
+			msg, err := s.CreateMessage(message, &channel)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg...)
+		}
+		fetched += len(history.Messages)
+
+		cursor = history.ResponseMetaData.NextCursor
+		if !history.HasMore || cursor == "" || len(history.Messages) == 0 {
+			return messages, nil
+		}
+		if query.Count > 0 && fetched >= query.Count {
+			return messages, nil
+		}
 	}
-	return messages, nil
+}
+
+// Timestamp converts a time to a Slack timestamp, e.g. to fetch the messages
+// posted since then.
+func Timestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
 }
 
 // CreateMessage will create a string formatted message that can be rendered
@@ -314,7 +447,7 @@ func (s *SlackService) CreateMessage(message slack.Message, channel *components.
 			}
 		} else {
 			// Not a bot, not in cache, get user info
-			user, err := s.Client.GetUserInfo(User)
+			user, err := s.getUserInfo(User)
 			if err != nil {
 				name = "unknown"
 				s.setCachedUser(User, name)
@@ -334,17 +467,27 @@ func (s *SlackService) CreateMessage(message slack.Message, channel *components.
 	if threadTimestamp == "" {
 		threadTimestamp = message.Timestamp
 	}
+	body := parseMessage(s, message.Text)
 	msg := components.Message{
+		Timestamp:       message.Timestamp,
 		ThreadTimestamp: threadTimestamp,
 		Channel:         channel,
 		Time:            parseTime(message),
+		UserID:          message.User,
 		Name:            name,
-		Content:         parseMessage(s, message.Text),
-		Attachments:     s.FormatAttachments(message.Attachments, message.Files),
-		IsReply:         message.ThreadTimestamp != "",
+		IsBot:           message.BotID != "",
+		Mention:         s.IsMention(message.Text),
+		Content:         components.PlainText(body),
+		Body:            body,
+		Attachments:     append(s.FormatBlocks(s.blocks.Take(channel.ID, message.Timestamp), message.Text != ""), s.FormatAttachments(message.Attachments, message.Files)...),
+		IsReply:         isReply(message.Msg),
+		Reactions:       reactionCounts(message.Reactions),
+		ReplyCount:      message.ReplyCount,
+		LastReply:       lastReply(message.Msg),
 	}
 
 	msgs = append(msgs, msg)
+	s.cache.Put(msg)
 
 	if len(message.Replies) > 0 {
 		replies, err := s.CreateMessageFromReplies(&message, channel)
@@ -370,6 +513,21 @@ func (s *SlackService) setCachedUser(ID string, Username string) {
 	s.UserCache[ID] = Username
 }
 
+// isReply returns whether the message is a reply in a thread, the parent of
+// a thread has a thread timestamp as well.
+func isReply(message slack.Msg) bool {
+	return message.ThreadTimestamp != "" && message.ThreadTimestamp != message.Timestamp
+}
+
+// lastReply returns the time of the last reply in the thread of a parent
+// message, zero when unknown.
+func lastReply(message slack.Msg) time.Time {
+	if len(message.Replies) == 0 {
+		return time.Time{}
+	}
+	return parseTime(slack.Message{Msg: slack.Msg{Timestamp: message.Replies[len(message.Replies)-1].Timestamp}})
+}
+
 func parseTime(message slack.Message) time.Time {
 	// Parse time
 	floatTime, err := strconv.ParseFloat(message.Timestamp, 64)
@@ -392,36 +550,9 @@ func parseTime(message slack.Message) time.Time {
 // https://godoc.org/github.com/nlopes/slack#Client.GetConversationReplies
 // https://godoc.org/github.com/nlopes/slack#GetConversationRepliesParameters
 func (s *SlackService) CreateMessageFromReplies(parentMessage *slack.Message, channel *components.Channel) ([]components.Message, error) {
-	msgs := make([]slack.Message, 0)
-
-	initReplies, _, initCur, err := s.Client.GetConversationReplies(
-		&slack.GetConversationRepliesParameters{
-			ChannelID: channel.ID,
-			Timestamp: parentMessage.ThreadTimestamp,
-			Limit:     200,
-		},
-	)
+	msgs, err := s.getReplies(channel, parentMessage.ThreadTimestamp, "")
 	if err != nil {
-		log.Fatal(err) // FIXME
-	}
-
-	msgs = append(msgs, initReplies...)
-
-	nextCur := initCur
-	for nextCur != "" {
-		conversationReplies, _, cursor, err := s.Client.GetConversationReplies(&slack.GetConversationRepliesParameters{
-			ChannelID: channel.ID,
-			Timestamp: parentMessage.ThreadTimestamp,
-			Cursor:    nextCur,
-			Limit:     200,
-		})
-
-		if err != nil {
-			log.Fatal(err) // FIXME
-		}
-
-		msgs = append(msgs, conversationReplies...)
-		nextCur = cursor
+		return nil, err
 	}
 
 	var replies []components.Message
@@ -447,40 +578,339 @@ func (s *SlackService) CreateMessageFromReplies(parentMessage *slack.Message, ch
 	return replies, nil
 }
 
-func (s *SlackService) ListenToEvents(watchChannels map[string]*components.Channel, printer func(components.Message, *slack.TeamInfo)) error {
-	for msg := range s.RTM.IncomingEvents {
-		switch ev := msg.Data.(type) {
-		case *slack.HelloEvent:
-			// Ignore hello
+// getReplies fetches every message of a thread, its parent included, or only
+// the replies posted after oldest when it is set.
+func (s *SlackService) getReplies(channel *components.Channel, threadTimestamp string, oldest string) ([]slack.Message, error) {
+	msgs := make([]slack.Message, 0)
+
+	cursor := ""
+	for {
+		var conversationReplies []slack.Message
+		var next string
+		err := s.limiter.Do("conversations.replies", func() (err error) {
+			conversationReplies, _, next, err = s.Client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+				ChannelID: channel.ID,
+				Timestamp: threadTimestamp,
+				Cursor:    cursor,
+				Oldest:    oldest,
+				Limit:     200,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, conversationReplies...)
+		if next == "" {
+			return msgs, nil
+		}
+		cursor = next
+	}
+}
+
+// GetThread returns the parent message of a thread followed by all its
+// replies, oldest first.
+func (s *SlackService) GetThread(channel components.Channel, threadTimestamp string) ([]components.Message, error) {
+	msgs, err := s.getReplies(&channel, threadTimestamp, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no thread %s in #%s", threadTimestamp, channel.Name)
+	}
 
-		case *slack.MessageEvent:
-			channel := watchChannels[ev.Channel]
-			if channel == nil {
+	thread := make([]components.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		// The replies are already listed, they must not be fetched again
+		// for the parent.
+		msg.Replies = nil
+		messages, err := s.CreateMessage(msg, &channel)
+		if err != nil {
+			return nil, err
+		}
+		thread = append(thread, messages...)
+	}
+	return components.Threads(thread), nil
+}
+
+// Sink receives the messages and the events of the watched channels, e.g. a
+// render.Renderer.
+type Sink interface {
+	Message(message components.Message) error
+	Edit(edit components.Edit) error
+	Delete(deletion components.Deletion) error
+	Gap(gap components.Gap) error
+	Reaction(reaction components.Reaction) error
+}
+
+// ListenToEvents passes the messages posted, edited and deleted in the
+// watched channels to the sink until the credentials are rejected or the
+// event source is closed. The source reconnects on its own after a
+// disconnection, the messages missed in the meantime are then fetched and
+// rendered after a gap marker.
+func (s *SlackService) ListenToEvents(watchChannels map[string]*components.Channel, sink Sink) error {
+	// The newest message rendered per channel, to backfill the channels
+	// from there after a disconnection. The channels without messages are
+	// backfilled from the start.
+	lastSeen := make(map[string]string)
+	start := Timestamp(time.Now())
+	var disconnectedAt time.Time
+	parents := newParentResolver(s, sink)
+
+	for ev := range s.Events.Events() {
+		select {
+		case err := <-parents.errs:
+			return err
+		default:
+		}
+		switch ev.Type {
+		case EventConnected:
+			if disconnectedAt.IsZero() {
 				continue
 			}
-			messages, err := s.CreateMessageFromMessageEvent(channel, ev)
+			log.Printf("%s: reconnected, fetching the missed messages ...", s.CurrentTeamInfo.Domain)
+			err := s.repairGap(watchChannels, lastSeen, start, disconnectedAt, sink)
 			if err != nil {
 				return err
 			}
-			for _, message := range messages {
-				printer(message, s.CurrentTeamInfo)
+			disconnectedAt = time.Time{}
+
+		case EventDisconnected:
+			if disconnectedAt.IsZero() {
+				disconnectedAt = time.Now()
+				log.Printf("%s: disconnected, reconnecting ...", s.CurrentTeamInfo.Domain)
 			}
 
-		case *slack.RTMError:
-			msg := fmt.Sprintf("Error: %s\n", ev.Error())
-			return errors.New(msg)
+		case EventConnectionError:
+			log.Printf("%s: connection attempt %d failed: %s", s.CurrentTeamInfo.Domain, ev.Attempt, ev.Err)
+
+		case EventMessage:
+			s.putBlocks(ev)
+			err := s.handleMessageEvent(ev.Message, watchChannels, lastSeen, sink, parents)
+			if err != nil {
+				return err
+			}
 
-		case *slack.InvalidAuthEvent:
+		case EventReactionAdded, EventReactionRemoved:
+			err := s.handleReactionEvent(ev.Reaction, ev.Type == EventReactionRemoved, watchChannels, sink)
+			if err != nil {
+				return err
+			}
+
+		case EventError:
+			// e.g. a message that could not be sent, the connection is
+			// still up.
+			log.Printf("%s: %s", s.CurrentTeamInfo.Domain, ev.Err)
+
+		case EventInvalidAuth:
 			msg := "Invalid credentials"
 			return errors.New(msg)
+		}
+	}
+	return nil
+}
 
-		default:
-			//fmt.Printf("%v\n", ev)
+// putBlocks keeps the blocks of the message of the event, if the transport
+// provides them, until the message is created.
+func (s *SlackService) putBlocks(ev Event) {
+	timestamp := ev.Message.Timestamp
+	if ev.Message.SubMessage != nil {
+		timestamp = ev.Message.SubMessage.Timestamp
+	}
+	s.blocks.Put(ev.Message.Channel, timestamp, ev.Blocks)
+}
+
+// handleMessageEvent renders a message posted, edited or deleted in a
+// watched channel.
+func (s *SlackService) handleMessageEvent(ev *slack.MessageEvent, watchChannels map[string]*components.Channel, lastSeen map[string]string, sink Sink, parents *parentResolver) error {
+	channel := watchChannels[ev.Channel]
+	if channel == nil {
+		return nil
+	}
+	switch ev.SubType {
+	case "message_deleted":
+		return sink.Delete(s.CreateDeletion(channel, ev))
+	case "message_changed":
+		edit, err := s.CreateEdit(channel, ev)
+		if err != nil || edit == nil {
+			return err
+		}
+		return sink.Edit(*edit)
+	}
+	// Skip the messages already rendered by a gap repair.
+	if components.CompareTimestamps(ev.Timestamp, lastSeen[ev.Channel]) <= 0 {
+		return nil
+	}
+	messages, err := s.CreateMessageFromMessageEvent(channel, ev)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		message.Live = true
+		err = parents.Message(message)
+		if err != nil {
+			return err
 		}
+		lastSeen[ev.Channel] = message.Timestamp
 	}
 	return nil
 }
 
+// fetchParent fetches the parent message of a thread from Slack. Nil is
+// returned when it cannot be fetched.
+func (s *SlackService) fetchParent(channel *components.Channel, threadTimestamp string) *components.Message {
+	var replies []slack.Message
+	err := s.limiter.Do("conversations.replies", func() (err error) {
+		replies, _, _, err = s.Client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+			ChannelID: channel.ID,
+			Timestamp: threadTimestamp,
+			Limit:     1,
+		})
+		return err
+	})
+	if err != nil || len(replies) == 0 {
+		return nil
+	}
+	// The parent comes first. Its replies are not needed.
+	parent := replies[0]
+	parent.Replies = nil
+	messages, err := s.CreateMessage(parent, channel)
+	if err != nil || len(messages) == 0 {
+		return nil
+	}
+	return &messages[0]
+}
+
+// handleReactionEvent renders a reaction added to, or removed from, a message
+// of a watched channel. The reactions of the message are updated when it is
+// known.
+func (s *SlackService) handleReactionEvent(ev *slack.ReactionAddedEvent, removed bool, watchChannels map[string]*components.Channel, sink Sink) error {
+	if ev.Item.Type != "message" {
+		return nil
+	}
+	channel := watchChannels[ev.Item.Channel]
+	if channel == nil {
+		return nil
+	}
+	name, ok := s.getCachedUser(ev.User)
+	if !ok {
+		name = "unknown"
+	}
+	reaction := components.Reaction{
+		Channel:   channel,
+		Timestamp: ev.Item.Timestamp,
+		Time:      parseTime(slack.Message{Msg: slack.Msg{Timestamp: ev.EventTimestamp}}),
+		UserID:    ev.User,
+		Name:      name,
+		EmojiName: ev.Reaction,
+		Emoji:     emoji(ev.Reaction),
+		Removed:   removed,
+	}
+	if message, ok := s.cache.Get(channel.ID, ev.Item.Timestamp); ok {
+		message.Reactions = message.Reactions.Update(reaction.EmojiName, reaction.Emoji, removed)
+		s.cache.Put(message)
+		reaction.Message = &message
+	}
+	return sink.Reaction(reaction)
+}
+
+// activeThreadWindow is how long before a disconnection a thread must have
+// been active for its missed replies to be fetched.
+const activeThreadWindow = 24 * time.Hour
+
+// repairGap fetches the messages of the watched channels posted since the
+// last message rendered, or since start when there is none, and renders them
+// after a marker. The channels are fetched concurrently, the ones that cannot
+// be fetched are logged and skipped.
+func (s *SlackService) repairGap(watchChannels map[string]*components.Channel, lastSeen map[string]string, start string, disconnectedAt time.Time, sink Sink) error {
+	now := time.Now()
+	channels := make([]*components.Channel, 0, len(watchChannels))
+	for _, channel := range watchChannels {
+		channels = append(channels, channel)
+	}
+	missed := make([][]components.Message, len(channels))
+	ForEach(len(channels), s.Concurrency, func(i int) {
+		oldest, ok := lastSeen[channels[i].ID]
+		if !ok {
+			oldest = start
+		}
+		messages, err := s.getMissed(channels[i], oldest, disconnectedAt.Add(-activeThreadWindow))
+		if err != nil {
+			log.Printf("%s: failed to fetch the messages missed in #%s: %s", s.CurrentTeamInfo.Domain, channels[i].Name, err)
+			return
+		}
+		missed[i] = messages
+	})
+
+	for i, channel := range channels {
+		id, messages := channel.ID, missed[i]
+		if len(messages) == 0 {
+			continue
+		}
+
+		err := sink.Gap(components.Gap{
+			Channel: channel,
+			Since:   disconnectedAt,
+			Until:   now,
+			Count:   len(messages),
+		})
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			err = sink.Message(message)
+			if err != nil {
+				return err
+			}
+			if components.CompareTimestamps(message.Timestamp, lastSeen[id]) > 0 {
+				lastSeen[id] = message.Timestamp
+			}
+		}
+	}
+	return nil
+}
+
+// getMissed returns the messages of the channel posted after oldest, oldest
+// first, with the replies posted meanwhile in the threads active since the
+// time. The history only lists the parents posted after oldest, the threads
+// known from the cache are fetched as well.
+func (s *SlackService) getMissed(channel *components.Channel, oldest string, activeSince time.Time) ([]components.Message, error) {
+	threads := s.cache.Threads(channel.ID, activeSince)
+	messages, err := s.GetHistory(*channel, HistoryQuery{Oldest: oldest})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, message := range messages {
+		seen[message.Timestamp] = true
+		if !message.IsReply && message.ReplyCount > 0 {
+			threads = append(threads, message.ThreadTimestamp)
+		}
+	}
+	for _, threadTimestamp := range threads {
+		replies, err := s.getReplies(channel, threadTimestamp, oldest)
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			// The parent is always listed.
+			if !isReply(reply.Msg) || seen[reply.Timestamp] || components.CompareTimestamps(reply.Timestamp, oldest) <= 0 {
+				continue
+			}
+			seen[reply.Timestamp] = true
+			reply.Replies = nil
+			created, err := s.CreateMessage(reply, channel)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, created...)
+		}
+	}
+	sort.Sort(sort.Reverse(components.Messages(messages)))
+	return messages, nil
+}
+
 func (s *SlackService) CreateMessageFromMessageEvent(channel *components.Channel, message *slack.MessageEvent) ([]components.Message, error) {
 
 	var msgs []components.Message
@@ -488,9 +918,8 @@ func (s *SlackService) CreateMessageFromMessageEvent(channel *components.Channel
 
 	switch message.SubType {
 	case "message_changed":
-		// Append (edited) when an edited message is received
+		// Render the new version of an edited message
 		message = &slack.MessageEvent{Msg: *message.SubMessage}
-		message.Text = fmt.Sprintf("%s (edited)", message.Text)
 	case "message_replied":
 		// Ignore reply events
 		return nil, nil
@@ -511,7 +940,7 @@ func (s *SlackService) CreateMessageFromMessageEvent(channel *components.Channel
 			}
 		} else {
 			// Not a bot, not in cache, get user info
-			user, err := s.Client.GetUserInfo(message.User)
+			user, err := s.getUserInfo(message.User)
 			if err != nil {
 				name = "unknown"
 				s.setCachedUser(message.User, name)
@@ -538,69 +967,89 @@ func (s *SlackService) CreateMessageFromMessageEvent(channel *components.Channel
 	if threadTimestamp == "" {
 		threadTimestamp = message.Timestamp
 	}
+	body := parseMessage(s, message.Text)
 	msg := components.Message{
+		Timestamp:       message.Timestamp,
 		Channel:         channel,
 		ThreadTimestamp: threadTimestamp,
 		Time:            time.Unix(intTime, 0),
+		UserID:          message.User,
 		Name:            name,
-		Content:         parseMessage(s, message.Text),
-		Attachments:     s.FormatAttachments(message.Attachments, message.Files),
+		IsBot:           message.BotID != "",
+		Mention:         s.IsMention(message.Text),
+		Content:         components.PlainText(body),
+		Body:            body,
+		Attachments:     append(s.FormatBlocks(s.blocks.Take(channel.ID, message.Timestamp), message.Text != ""), s.FormatAttachments(message.Attachments, message.Files)...),
+		IsReply:         isReply(message.Msg),
+		Reactions:       reactionCounts(message.Reactions),
+		ReplyCount:      message.ReplyCount,
+		LastReply:       lastReply(message.Msg),
 	}
 
 	msgs = append(msgs, msg)
+	s.cache.Put(msg)
 
 	return msgs, nil
 }
 
-func parseMessage(s *SlackService, msg string) string {
-	msg = parseEmoji(msg)
-	msg = parseMentions(s, msg)
-	return msg
+// CreateEdit will create the components.Edit of a message_changed event. The
+// original message is known when it was seen since slag started. Nil is
+// returned when neither the content nor the attachments changed, e.g. when
+// a reply is posted in the message's thread.
+func (s *SlackService) CreateEdit(channel *components.Channel, message *slack.MessageEvent) (*components.Edit, error) {
+	if message.SubMessage == nil {
+		return nil, nil
+	}
+	edit := &components.Edit{}
+	if original, ok := s.cache.Get(channel.ID, message.SubMessage.Timestamp); ok {
+		edit.Original = &original
+	}
+	messages, err := s.CreateMessageFromMessageEvent(channel, message)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	edit.Message = messages[0]
+	if edit.Original != nil &&
+		edit.Original.Content == edit.Message.Content &&
+		sameAttachments(edit.Original.Attachments, edit.Message.Attachments) {
+		return nil, nil
+	}
+	return edit, nil
 }
 
-// parseMentions will try to find mention placeholders in the message
-// string and replace them with the correct username with and @ symbol
-//
-// Mentions have the following format:
-//	<@U12345|erroneousboat>
-//		<@U12345>
-func parseMentions(s *SlackService, msg string) string {
-	r := regexp.MustCompile(`\<@(\w+\|*\w+)\>`)
-
-	return r.ReplaceAllStringFunc(
-		msg, func(str string) string {
-			rs := r.FindStringSubmatch(str)
-			if len(rs) < 1 {
-				return str
-			}
-
-			var userID string
-			split := strings.Split(rs[1], "|")
-			if len(split) > 0 {
-				userID = split[0]
-			} else {
-				userID = rs[1]
-			}
-
-			name, ok := s.getCachedUser(userID)
-			if !ok {
-				user, err := s.Client.GetUserInfo(userID)
-				if err != nil {
-					name = "unknown"
-					s.setCachedUser(userID, name)
-				} else {
-					name = user.Name
-					s.setCachedUser(userID, user.Name)
-				}
-			}
+// sameAttachments returns whether both lists have the same attachments, e.g.
+// unchanged by an edit. An unfurl replacing another one changes them.
+func sameAttachments(a []components.Attachment, b []components.Attachment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Content != b[i].Content || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
 
-			if name == "" {
-				name = "unknown"
-			}
+// CreateDeletion will create the components.Deletion of a message_deleted
+// event. The original message is known when it was seen since slag started.
+func (s *SlackService) CreateDeletion(channel *components.Channel, message *slack.MessageEvent) components.Deletion {
+	deletion := components.Deletion{
+		Timestamp: message.DeletedTimestamp,
+		Channel:   channel,
+		Time:      parseTime(slack.Message{Msg: slack.Msg{Timestamp: message.DeletedTimestamp}}),
+	}
+	if original, ok := s.cache.Get(channel.ID, message.DeletedTimestamp); ok {
+		deletion.Original = &original
+		s.cache.Remove(channel.ID, message.DeletedTimestamp)
+	}
+	return deletion
+}
 
-			return "@" + name
-		},
-	)
+func parseMessage(s *SlackService, msg string) []components.Node {
+	msg = parseEmoji(msg)
+	msg = parseEntities(s, msg)
+	return parseMrkdwn(msg)
 }
 
 // parseEmoji will try to find emoji placeholders in the message
@@ -619,6 +1068,30 @@ func parseEmoji(msg string) string {
 	)
 }
 
+// emoji returns the emoji of a Slack emoji name, e.g. 'thumbsup', or the
+// name between colons when unknown. The skin tone is dropped.
+func emoji(name string) string {
+	name = strings.SplitN(name, "::", 2)[0]
+	code, ok := EmojiCodemap[":"+name+":"]
+	if !ok {
+		return ":" + name + ":"
+	}
+	return code
+}
+
+// reactionCounts converts the reactions of a message.
+func reactionCounts(reactions []slack.ItemReaction) components.Reactions {
+	var counts components.Reactions
+	for _, reaction := range reactions {
+		counts = append(counts, components.ReactionCount{
+			Name:  reaction.Name,
+			Emoji: emoji(reaction.Name),
+			Count: reaction.Count,
+		})
+	}
+	return counts
+}
+
 // FormatAttachments will construct a array of string of the Field
 // values of Attachments from a Message.
 func (s *SlackService) FormatAttachments(attachments []slack.Attachment, files []slack.File) []components.Attachment {
@@ -634,21 +1107,25 @@ func (s *SlackService) FormatAttachments(attachments []slack.Attachment, files [
 		if attachment.Title != "" {
 			finalAttachments = append(
 				finalAttachments,
-				components.Attachment{Content: SanitizeLinks(attachment.Title), Type: "title"},
+				s.parseAttachment(attachment.Title, "title"),
 			)
 		}
 
 		if attachment.TitleLink != "" {
 			finalAttachments = append(
 				finalAttachments,
-				components.Attachment{Content: SanitizeLinks(attachment.TitleLink), Type: "link"},
+				components.Attachment{
+					Content: attachment.TitleLink,
+					Type:    "link",
+					Body:    []components.Node{{Type: components.NodeLink, Text: attachment.TitleLink}},
+				},
 			)
 		}
 
 		if attachment.Text != "" {
 			finalAttachments = append(
 				finalAttachments,
-				components.Attachment{Content: SanitizeLinks(attachment.Text), Type: "text"},
+				s.parseAttachment(attachment.Text, "text"),
 			)
 		}
 
@@ -674,24 +1151,33 @@ func (s *SlackService) FormatAttachments(attachments []slack.Attachment, files [
 	return finalAttachments
 }
 
-var regex *regexp.Regexp
-
-func init() {
-	var err error
-	regex, err = regexp.Compile("(<)(https://.*?)([|>])")
-	if err != nil {
-		log.Fatal("Failed to init regex")
-	}
-}
-func SanitizeLinks(message string) string {
-	return regex.ReplaceAllString(message, "$1 $2 $3")
+// parseAttachment parses the text of an attachment like the one of a
+// message: its emoji, mentions and links are resolved.
+func (s *SlackService) parseAttachment(text string, kind string) components.Attachment {
+	body := parseMessage(s, text)
+	return components.Attachment{Content: components.PlainText(body), Type: kind, Body: body}
 }
 
 func (s *SlackService) createChannelItem(chn slack.Channel) components.Channel {
 	return components.Channel{
-		ID:     chn.ID,
-		Name:   chn.Name,
-		Topic:  chn.Topic.Value,
-		UserID: chn.User,
+		ID:        chn.ID,
+		Workspace: s.CurrentTeamInfo.Domain,
+		Name:      chn.Name,
+		Topic:     chn.Topic.Value,
+		Type:      channelType(chn),
+		UserID:    chn.User,
+	}
+}
+
+func channelType(chn slack.Channel) string {
+	switch {
+	case chn.IsIM:
+		return components.ChannelTypeIM
+	case chn.IsMpIM:
+		return components.ChannelTypeMpIM
+	case chn.IsGroup || chn.IsPrivate:
+		return components.ChannelTypeGroup
+	default:
+		return components.ChannelTypeChannel
 	}
 }
@@ -1,21 +1,265 @@
 package service
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/j-martin/slag/components"
+
+	"github.com/nlopes/slack"
 )
 
 func TestSanitizeLinks(t *testing.T) {
 	assertFormat(t,
 		"fff <https://github.com/alloytech/alloy/commit/0566e3fc4fba|1 new commit> pu",
-		"fff < https://github.com/alloytech/alloy/commit/0566e3fc4fba |1 new commit> pu")
+		"fff 1 new commit (https://github.com/alloytech/alloy/commit/0566e3fc4fba) pu")
 	assertFormat(t,
 		"fff <https://github.com/alloytech/alloy/commit/0566e3fc4fba> pu",
-		"fff < https://github.com/alloytech/alloy/commit/0566e3fc4fba > pu")
+		"fff https://github.com/alloytech/alloy/commit/0566e3fc4fba pu")
+	assertFormat(t,
+		"<http://example.com|example.com> &amp; <@U1>",
+		"example.com & @user")
 }
 
 func assertFormat(t *testing.T, input string, expectedString string) {
-	matchString := SanitizeLinks(input)
+	s := &SlackService{
+		UserCache: map[string]string{"U1": "user"},
+		mutex:     &sync.Mutex{},
+	}
+	attachment := s.FormatAttachments([]slack.Attachment{{Text: input}}, nil)[0]
+	matchString := attachment.Content
 	if matchString != expectedString {
 		t.Errorf("'%s' not equal to '%s'", matchString, expectedString)
 	}
 }
+
+func TestIsMention(t *testing.T) {
+	s := &SlackService{
+		CurrentUserID: "U1",
+		mutex:         &sync.Mutex{},
+		userGroups: map[string]slack.UserGroup{
+			"S1": {ID: "S1", Users: []string{"U1", "U2"}},
+			"S2": {ID: "S2", Users: []string{"U2"}},
+		},
+	}
+	// The user groups are already loaded
+	s.userGroupsOnce.Do(func() {})
+
+	tests := map[string]bool{
+		"hello":                         false,
+		"hello <@U1>":                   true,
+		"hello <@U1|erroneousboat>":     true,
+		"hello <@U2>":                   false,
+		"<!here> deploy is starting":    true,
+		"<!channel|channel> heads up":   true,
+		"<!everyone>":                   true,
+		"<!subteam^S1|@oncall> help":    true,
+		"<!subteam^S2|@frontend> help":  false,
+		"<!date^1392734382^{date}|Feb>": false,
+	}
+	for text, expected := range tests {
+		if mention := s.IsMention(text); mention != expected {
+			t.Errorf("'%s': expected %v, got %v", text, expected, mention)
+		}
+	}
+}
+
+func TestParseEntities(t *testing.T) {
+	location := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	defer func() { time.Local = location }()
+
+	s := &SlackService{
+		UserCache: map[string]string{"U1": "erroneousboat"},
+		Channels: map[string]components.Channel{
+			"C1": {ID: "C1", Name: "general"},
+		},
+		mutex: &sync.Mutex{},
+		userGroups: map[string]slack.UserGroup{
+			"S1": {ID: "S1", Handle: "oncall"},
+		},
+	}
+	s.userGroupsOnce.Do(func() {})
+
+	tests := map[string]string{
+		"hi <@U1>":                        "hi @erroneousboat",
+		"hi <@U1|erroneousboat>":          "hi @erroneousboat",
+		"see <#C1>":                       "see #general",
+		"see <#C1|general>":               "see #general",
+		"see <#C2|random>":                "see #random",
+		"see <#C2>":                       "see #C2",
+		"<!subteam^S1|@oncall-team> help": "@oncall-team help",
+		"<!subteam^S1> help":              "@oncall help",
+		"<!subteam^S2> help":              "@subteam help",
+		"<!here> <!here|here> <!channel> <!everyone>":               "@here here @channel @everyone",
+		"<!date^1392734382^{date_num} {time_secs}|Feb 18>":          "2014-02-18 9:39:42 AM",
+		"<!date^1392734382^{date}|Feb 18>":                          "February 18th, 2014",
+		"<!date^1392734382^{date_short} at {time}|Feb 18>":          "Feb 18, 2014 at 9:39 AM",
+		"<!date^1392734382^{date_long}^https://example.com|Feb 18>": "Tuesday, February 18th, 2014",
+		"<!date^1392734382^{date_pretty}|Feb 18>":                   "February 18th, 2014",
+		"<!date^1392734382^{date_short_pretty}|Feb 18>":             "Feb 18, 2014",
+		"<!date^1392734382^{unknown}|Feb 18>":                       "Feb 18",
+		"<!date^tomorrow^{date}|Feb 18>":                            "Feb 18",
+		"<https://example.com|example>":                             "<https://example.com|example>",
+	}
+	for text, expected := range tests {
+		if parsed := parseEntities(s, text); parsed != expected {
+			t.Errorf("'%s': '%s' not equal to '%s'", text, parsed, expected)
+		}
+	}
+}
+
+func TestFormatDateToken(t *testing.T) {
+	location := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = location }()
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"{date_pretty}":       "today",
+		"{date_short_pretty}": "yesterday",
+		"{date_long_pretty}":  "tomorrow",
+	}
+	days := map[string]time.Time{
+		"{date_pretty}":       now,
+		"{date_short_pretty}": now.AddDate(0, 0, -1),
+		"{date_long_pretty}":  now.AddDate(0, 0, 1),
+	}
+	for format, expected := range tests {
+		token := fmt.Sprintf("date^%d^%s", days[format].Unix(), format)
+		if date, ok := formatDateToken(token, now); !ok || date != expected {
+			t.Errorf("'%s': '%s' not equal to '%s'", token, date, expected)
+		}
+	}
+	for day, expected := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 31: "31st"} {
+		if suffixed := ordinal(day); suffixed != expected {
+			t.Errorf("%d: '%s' not equal to '%s'", day, suffixed, expected)
+		}
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	// 5 messages, newest first like the Slack API.
+	var limits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		limits = append(limits, r.Form.Get("limit"))
+		offset, _ := strconv.Atoi(r.Form.Get("cursor"))
+		limit, _ := strconv.Atoi(r.Form.Get("limit"))
+		messages := ""
+		for i := offset; i < offset+limit && i < 5; i++ {
+			if messages != "" {
+				messages += ","
+			}
+			messages += fmt.Sprintf(`{"type":"message","user":"U1","text":"m%d","ts":"153800000%d.000100"}`, i, 5-i)
+		}
+		next := ""
+		if offset+limit < 5 {
+			next = strconv.Itoa(offset + limit)
+		}
+		fmt.Fprintf(w, `{"ok":true,"messages":[%s],"has_more":%t,"response_metadata":{"next_cursor":"%s"}}`,
+			messages, next != "", next)
+	}))
+	defer server.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = server.URL + "/"
+
+	tests := []struct {
+		query    HistoryQuery
+		expected int
+		limits   []string
+	}{
+		{HistoryQuery{PageSize: 2}, 5, []string{"2", "2", "2"}},
+		{HistoryQuery{Count: 3, PageSize: 2}, 3, []string{"2", "1"}},
+		{HistoryQuery{Count: 3}, 3, []string{"3"}},
+	}
+	for _, test := range tests {
+		limits = nil
+		s := &SlackService{
+			Client:    slack.New("token"),
+			UserCache: map[string]string{"U1": "jane"},
+			mutex:     &sync.Mutex{},
+			limiter:   NewLimiter(),
+			cache:     newMessageCache(messageCacheCapacity),
+			blocks:    newBlockStore(messageCacheCapacity),
+		}
+		messages, err := s.GetHistory(components.Channel{ID: "C1"}, test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != test.expected {
+			t.Errorf("%+v: expected %d messages, got %d", test.query, test.expected, len(messages))
+		}
+		if fmt.Sprint(limits) != fmt.Sprint(test.limits) {
+			t.Errorf("%+v: expected the limits %v, got %v", test.query, test.limits, limits)
+		}
+	}
+}
+
+func TestGetMissed(t *testing.T) {
+	replies := map[string]string{
+		// Posted during the disconnection, with a reply.
+		"1538000100.000100": `{"user":"U1","text":"parent","ts":"1538000100.000100","thread_ts":"1538000100.000100","reply_count":1},
+			{"user":"U1","text":"new reply","ts":"1538000101.000100","thread_ts":"1538000100.000100"}`,
+		// Cached, with a reply before and one after the disconnection.
+		"1537990000.000100": `{"user":"U1","text":"old parent","ts":"1537990000.000100","thread_ts":"1537990000.000100","reply_count":2},
+			{"user":"U1","text":"old reply","ts":"1537990001.000100","thread_ts":"1537990000.000100"},
+			{"user":"U1","text":"missed reply","ts":"1538000102.000100","thread_ts":"1537990000.000100"}`,
+		// Cached, but inactive for too long.
+		"1500000000.000100": `{"user":"U1","text":"stale parent","ts":"1500000000.000100","thread_ts":"1500000000.000100","reply_count":1},
+			{"user":"U1","text":"stale reply","ts":"1538000103.000100","thread_ts":"1500000000.000100"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		messages := `{"user":"U1","text":"plain","ts":"1538000050.000100"},` + strings.SplitN(replies["1538000100.000100"], ",\n", 2)[0]
+		if strings.HasSuffix(r.URL.Path, "conversations.replies") {
+			messages = replies[r.Form.Get("ts")]
+		}
+		fmt.Fprintf(w, `{"ok":true,"messages":[%s],"has_more":false}`, messages)
+	}))
+	defer server.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = server.URL + "/"
+
+	s := &SlackService{
+		Client:    slack.New("token"),
+		UserCache: map[string]string{"U1": "jane"},
+		mutex:     &sync.Mutex{},
+		limiter:   NewLimiter(),
+		cache:     newMessageCache(messageCacheCapacity),
+		blocks:    newBlockStore(messageCacheCapacity),
+	}
+	channel := &components.Channel{ID: "C1"}
+	s.cache.Put(components.Message{Channel: channel, Timestamp: "1537990001.000100", ThreadTimestamp: "1537990000.000100", IsReply: true, Time: time.Unix(1537990001, 0)})
+	s.cache.Put(components.Message{Channel: channel, Timestamp: "1500000000.000100", ThreadTimestamp: "1500000000.000100", ReplyCount: 1, Time: time.Unix(1500000000, 0)})
+
+	messages, err := s.getMissed(channel, "1538000000.000100", time.Unix(1537900000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make([]string, 0, len(messages))
+	for _, message := range messages {
+		contents = append(contents, message.Content)
+	}
+	expected := []string{"plain", "parent", "new reply", "missed reply"}
+	if fmt.Sprint(contents) != fmt.Sprint(expected) {
+		t.Errorf("%q not equal to %q", contents, expected)
+	}
+}
+
+func TestSameAttachments(t *testing.T) {
+	unfurl := []components.Attachment{{Type: "title", Content: "Example (https://example.com)"}}
+	other := []components.Attachment{{Type: "title", Content: "Other (https://example.org)"}}
+	if !sameAttachments(unfurl, []components.Attachment{unfurl[0]}) {
+		t.Error("expected the same attachments")
+	}
+	if sameAttachments(unfurl, other) || sameAttachments(unfurl, nil) {
+		t.Error("expected different attachments")
+	}
+}
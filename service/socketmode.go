@@ -0,0 +1,252 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+// errInvalidAuth is returned when Slack rejects the app-level token.
+var errInvalidAuth = errors.New("invalid app-level token")
+
+// socketModeEnvelope is a message received over a Socket Mode connection.
+//
+// https://api.slack.com/apis/connections/socket-implement
+type socketModeEnvelope struct {
+	Type       string `json:"type"`
+	EnvelopeID string `json:"envelope_id"`
+	Reason     string `json:"reason"`
+	Payload    struct {
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// socketModeSource receives the events of a Slack app over Socket Mode, with
+// an app-level token. The app must subscribe to the message and reaction
+// events, e.g. 'message.channels', 'message.im' and 'reaction_added'.
+type socketModeSource struct {
+	appToken string
+	events   chan Event
+	done     chan struct{}
+	once     sync.Once
+	conn     *websocket.Conn
+	mutex    sync.Mutex
+}
+
+// NewSocketModeSource connects to Socket Mode with the app-level token
+// (xapp-...), and reconnects whenever the connection is lost or Slack asks
+// for it.
+func NewSocketModeSource(appToken string) EventSource {
+	s := &socketModeSource{
+		appToken: appToken,
+		events:   make(chan Event),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *socketModeSource) Events() <-chan Event {
+	return s.events
+}
+
+func (s *socketModeSource) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func (s *socketModeSource) run() {
+	defer close(s.events)
+	attempt := 0
+	for {
+		attempt++
+		conn, err := s.connect()
+		if err == errInvalidAuth {
+			s.send(Event{Type: EventInvalidAuth})
+			return
+		}
+		if err != nil {
+			if !s.send(Event{Type: EventConnectionError, Attempt: attempt, Err: err}) {
+				return
+			}
+			select {
+			case <-time.After(reconnectDelay(attempt)):
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		attempt = 0
+		err = s.read(conn)
+		conn.Close()
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		if err != nil && !s.send(Event{Type: EventError, Err: err}) {
+			return
+		}
+		if !s.send(Event{Type: EventDisconnected}) {
+			return
+		}
+	}
+}
+
+// send passes the event on, unless the source is closed.
+func (s *socketModeSource) send(event Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// connect requests a Socket Mode URL and opens the connection.
+func (s *socketModeSource) connect() (*websocket.Conn, error) {
+	request, err := http.NewRequest("POST", slack.APIURL+"apps.connections.open", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+s.appToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("apps.connections.open: %s", response.Status)
+	}
+	var body struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+		URL   string `json:"url"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+	switch body.Error {
+	case "":
+	case "invalid_auth", "not_authed", "account_inactive", "token_revoked":
+		return nil, errInvalidAuth
+	default:
+		return nil, fmt.Errorf("apps.connections.open: %s", body.Error)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(body.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+	return conn, nil
+}
+
+// read passes the events of the connection on, until Slack asks to
+// reconnect or the connection fails.
+func (s *socketModeSource) read(conn *websocket.Conn) error {
+	for {
+		var envelope socketModeEnvelope
+		err := conn.ReadJSON(&envelope)
+		if err != nil {
+			return err
+		}
+
+		// Every envelope must be acknowledged, otherwise Slack sends it
+		// again.
+		if envelope.EnvelopeID != "" {
+			err = conn.WriteJSON(map[string]string{"envelope_id": envelope.EnvelopeID})
+			if err != nil {
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case "hello":
+			if !s.send(Event{Type: EventConnected}) {
+				return nil
+			}
+
+		case "disconnect":
+			// e.g. 'refresh_requested', before the connection expires.
+			return nil
+
+		case "events_api":
+			var event struct {
+				Type string `json:"type"`
+			}
+			err = json.Unmarshal(envelope.Payload.Event, &event)
+			if err != nil {
+				continue
+			}
+			var ev Event
+			switch event.Type {
+			case "message":
+				ev = Event{Type: EventMessage, Message: &slack.MessageEvent{}}
+				err = json.Unmarshal(envelope.Payload.Event, ev.Message)
+				if err == nil {
+					ev.Blocks = messageBlocks(envelope.Payload.Event)
+				}
+			case "reaction_added", "reaction_removed":
+				ev = Event{Type: EventReactionAdded, Reaction: &slack.ReactionAddedEvent{}}
+				if event.Type == "reaction_removed" {
+					ev.Type = EventReactionRemoved
+				}
+				err = json.Unmarshal(envelope.Payload.Event, ev.Reaction)
+			default:
+				continue
+			}
+			if err != nil {
+				ev = Event{Type: EventError, Err: err}
+			}
+			if !s.send(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// messageBlocks returns the blocks of a message event, or of the new version
+// of an edited message.
+func messageBlocks(event json.RawMessage) []Block {
+	var message struct {
+		Blocks  []Block `json:"blocks"`
+		Message *struct {
+			Blocks []Block `json:"blocks"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(event, &message) != nil {
+		return nil
+	}
+	if message.Message != nil {
+		return message.Message.Blocks
+	}
+	return message.Blocks
+}
+
+// reconnectDelay returns the delay before the next connection attempt, it
+// doubles on every attempt up to 5 minutes.
+func reconnectDelay(attempt int) time.Duration {
+	delay := time.Second << uint(attempt-1)
+	if delay <= 0 || delay > 5*time.Minute {
+		delay = 5 * time.Minute
+	}
+	return delay
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+func TestSocketModeSource(t *testing.T) {
+	acks := make(chan string, 3)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/apps.connections.open" {
+			if r.Header.Get("Authorization") != "Bearer xapp-1" {
+				fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"ok":true,"url":"ws%s/link"}`, strings.TrimPrefix(server.URL, "http"))
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"events_api","envelope_id":"e1","payload":{"event":{"type":"team_join"}}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"events_api","envelope_id":"e2","payload":{"event":{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"1538000000.000100","blocks":[{"type":"divider"}]}}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"events_api","envelope_id":"e3","payload":{"event":{"type":"reaction_removed","user":"U2","reaction":"tada","item":{"type":"message","channel":"C1","ts":"1538000000.000100"}}}}`))
+		for i := 0; i < 3; i++ {
+			var ack map[string]string
+			if err := conn.ReadJSON(&ack); err != nil {
+				t.Error(err)
+				return
+			}
+			acks <- ack["envelope_id"]
+		}
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = server.URL + "/"
+
+	source := NewSocketModeSource("xapp-1")
+	defer source.Close()
+	expect := func(eventType EventType) Event {
+		select {
+		case ev := <-source.Events():
+			if ev.Type != eventType {
+				t.Fatalf("expected the event %d, got %+v", eventType, ev)
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the event %d", eventType)
+		}
+		return Event{}
+	}
+	expect(EventConnected)
+	ev := expect(EventMessage)
+	if ev.Message.Channel != "C1" || ev.Message.Text != "hello" || ev.Message.Timestamp != "1538000000.000100" {
+		t.Errorf("unexpected message: %+v", ev.Message)
+	}
+	if len(ev.Blocks) != 1 || ev.Blocks[0].Type != "divider" {
+		t.Errorf("unexpected blocks: %+v", ev.Blocks)
+	}
+	ev = expect(EventReactionRemoved)
+	if ev.Reaction.Reaction != "tada" || ev.Reaction.Item.Channel != "C1" || ev.Reaction.Item.Timestamp != "1538000000.000100" {
+		t.Errorf("unexpected reaction: %+v", ev.Reaction)
+	}
+	for _, expected := range []string{"e1", "e2", "e3"} {
+		if ack := <-acks; ack != expected {
+			t.Errorf("expected the ack of '%s', got '%s'", expected, ack)
+		}
+	}
+
+	invalid := NewSocketModeSource("xapp-2")
+	defer invalid.Close()
+	if ev := <-invalid.Events(); ev.Type != EventInvalidAuth {
+		t.Errorf("expected an invalid auth event, got %+v", ev)
+	}
+}
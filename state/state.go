@@ -0,0 +1,165 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/j-martin/slag/components"
+)
+
+// Path returns the default location of the state file, following the XDG
+// base directory specification.
+func Path() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(dir, "slag", "last-seen.json")
+}
+
+// saveDelay is how long the updates are gathered before the file is saved,
+// so that e.g. a backfill of thousands of messages saves it once.
+const saveDelay = 2 * time.Second
+
+// LastSeen tracks the timestamp of the newest message seen in each channel,
+// per workspace, so that the next run can resume from there. It implements
+// render.Renderer to follow the stream, and saves the file shortly after the
+// updates and when the stream ends.
+type LastSeen struct {
+	path       string
+	timestamps map[string]map[string]string
+	mutex      sync.Mutex
+	// timer saves the pending updates, nil when there are none.
+	timer *time.Timer
+	// err is the error of the last save in the background, returned by the
+	// next call.
+	err error
+}
+
+// Load reads the state file at path. A missing file yields an empty state.
+func Load(path string) (*LastSeen, error) {
+	l := &LastSeen{
+		path:       path,
+		timestamps: make(map[string]map[string]string),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &l.timestamps)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get returns the timestamp of the newest message seen in the channel.
+func (l *LastSeen) Get(channel *components.Channel) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	timestamp, ok := l.timestamps[channel.Workspace][channel.ID]
+	return timestamp, ok
+}
+
+// Update records the timestamp if it is newer than the one known for the
+// channel.
+func (l *LastSeen) Update(channel *components.Channel, timestamp string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	channels, ok := l.timestamps[channel.Workspace]
+	if !ok {
+		channels = make(map[string]string)
+		l.timestamps[channel.Workspace] = channels
+	}
+	if components.CompareTimestamps(timestamp, channels[channel.ID]) <= 0 {
+		return nil
+	}
+	channels[channel.ID] = timestamp
+	if l.timer == nil {
+		l.timer = time.AfterFunc(saveDelay, l.flush)
+	}
+	return l.takeErr()
+}
+
+// flush saves the pending updates, in the background.
+func (l *LastSeen) flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.timer == nil {
+		return
+	}
+	l.timer = nil
+	l.err = l.save()
+}
+
+// takeErr returns and clears the error of the last save in the background.
+// The mutex must be held.
+func (l *LastSeen) takeErr() error {
+	err := l.err
+	l.err = nil
+	return err
+}
+
+// save writes the file atomically. The mutex must be held.
+func (l *LastSeen) save() error {
+	data, err := json.Marshal(l.timestamps)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(l.path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *LastSeen) Start() error {
+	return nil
+}
+
+func (l *LastSeen) Message(message components.Message) error {
+	if message.Channel == nil || message.Timestamp == "" {
+		return nil
+	}
+	return l.Update(message.Channel, message.Timestamp)
+}
+
+func (l *LastSeen) Edit(edit components.Edit) error {
+	return nil
+}
+
+func (l *LastSeen) Delete(deletion components.Deletion) error {
+	return nil
+}
+
+func (l *LastSeen) Gap(gap components.Gap) error {
+	return nil
+}
+
+func (l *LastSeen) Reaction(reaction components.Reaction) error {
+	return nil
+}
+
+// End saves the pending updates.
+func (l *LastSeen) End() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.timer == nil {
+		return l.takeErr()
+	}
+	l.timer.Stop()
+	l.timer = nil
+	return l.save()
+}
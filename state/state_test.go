@@ -0,0 +1,45 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+)
+
+func TestLastSeen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slag-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slag", "last-seen.json")
+
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := &components.Channel{ID: "C1", Workspace: "acme"}
+	l.Message(components.Message{Channel: channel, Timestamp: "1538000000.000200"})
+	l.Message(components.Message{Channel: channel, Timestamp: "1538000000.000100"})
+	// The updates are saved later, or when the stream ends.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state saved on every update: %v", err)
+	}
+	if err := l.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp, ok := l.Get(channel); !ok || timestamp != "1538000000.000200" {
+		t.Errorf("unexpected timestamp: '%s'", timestamp)
+	}
+	if _, ok := l.Get(&components.Channel{ID: "C2", Workspace: "acme"}); ok {
+		t.Error("unexpected timestamp for an unknown channel")
+	}
+}
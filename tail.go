@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/j-martin/slag/archive"
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/filter"
+	"github.com/j-martin/slag/render"
+	"github.com/j-martin/slag/service"
+)
+
+const TAIL_USAGE = `USAGE:
+		slag tail [OPTIONS] DOMAIN CHANNEL
+		slag thread [OPTIONS] DOMAIN PERMALINK
+
+Follows a single conversation: 'tail' prints the latest messages of a channel
+then the new ones, 'thread' prints a whole thread then its new replies.
+
+ARGUMENTS
+	 DOMAIN    Domain/workspace to use.
+	 CHANNEL   '#channel' or '@user' for direct messages.
+	 PERMALINK Link to the thread, as printed by slag or copied from Slack,
+	           e.g. 'https://acme.slack.com/archives/C123/p1538000000000100'.
+
+OPTIONS:
+	 -n [INT]          Number of previous messages to display, 'tail' only.
+	                   Default: 20
+	 -quiet [DURATION] Exit once no new message was posted in the conversation
+	                   for the duration, e.g. '30m'. Default: never.
+	 -help, -h
+
+The global options, the message filters and the profile of the domain apply,
+e.g. 'slag -o compact -bots none thread acme LINK'.
+`
+
+// tail follows the messages of a channel.
+func tail(args []string) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	count := flags.Int("n", 20, "Number of previous messages to display.")
+	quiet := flags.Duration("quiet", 0, "Exit once the conversation is quiet for the duration.")
+	flags.Usage = func() {
+		fmt.Print(TAIL_USAGE)
+	}
+	args = parseInterspersed(flags, args)
+	if len(args) != 2 {
+		flags.Usage()
+		log.Fatal("The domain and the channel must be passed as arguments.")
+	}
+
+	loadProfile(args[0])
+	svc, err := newService(args[0], true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	channel, err := svc.ResolveChannel(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	var messages []components.Message
+	if *count > 0 {
+		messages, err = svc.GetHistory(channel, service.HistoryQuery{Count: *count, PageSize: flagPageSize})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flagThreads {
+		messages = components.Threads(messages)
+	} else {
+		sort.Sort(sort.Reverse(components.Messages(messages)))
+	}
+	follow(args[0], svc, channel, messages, "", renderOptions(), *quiet)
+}
+
+// thread follows the replies of a thread.
+func thread(args []string) {
+	flags := flag.NewFlagSet("thread", flag.ExitOnError)
+	quiet := flags.Duration("quiet", 0, "Exit once the thread is quiet for the duration.")
+	flags.Usage = func() {
+		fmt.Print(TAIL_USAGE)
+	}
+	args = parseInterspersed(flags, args)
+	if len(args) != 2 {
+		flags.Usage()
+		log.Fatal("The domain and the permalink must be passed as arguments.")
+	}
+
+	link, threadTimestamp, err := components.ParsePermalink(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	loadProfile(args[0])
+	svc, err := newService(args[0], true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The domain is the name of the credentials, not necessarily the one of
+	// the workspace.
+	if !strings.EqualFold(link.Workspace, svc.CurrentTeamInfo.Domain) {
+		log.Fatalf("The permalink is not in the '%s' workspace.", svc.CurrentTeamInfo.Domain)
+	}
+	channel, err := svc.ResolveChannel(link.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	messages, err := svc.GetThread(channel, threadTimestamp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := renderOptions()
+	options.Threaded = true
+	follow(args[0], svc, channel, messages, threadTimestamp, options, *quiet)
+}
+
+// follow renders the messages, then the new messages of the channel, or of
+// the thread when set, matched by the message filters, until the credentials
+// are rejected or, when quiet is set, no message was matched for that long.
+func follow(domain string, svc *service.SlackService, channel components.Channel, messages []components.Message, thread string, options render.Options, quiet time.Duration) {
+	f, err := newFilter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The conversation is selected explicitly, whatever its type.
+	f.ChannelTypes = nil
+	f.Thread = thread
+	options.Mentions[svc.CurrentTeamInfo.Domain] = "@" + svc.CurrentUsername
+	svc.Threaded = options.Threaded
+	renderer, err := render.New(flagOutputFormat, os.Stdout, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if quiet > 0 {
+		renderer = newQuietRenderer(renderer, quiet, func() {
+			log.Printf("No new message for %s, exiting.", quiet)
+			svc.Events.Close()
+		})
+	}
+	renderer = render.Synchronized(filter.Renderer(renderer, f))
+	if flagArchive {
+		messageArchive := archive.New(flagArchiveDir)
+		if err := messageArchive.Link(domain, svc.CurrentTeamInfo.Domain); err != nil {
+			log.Printf("%s: %s", domain, err)
+		}
+		renderer = render.Multi(renderer, messageArchive)
+	}
+
+	err = renderer.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, message := range messages {
+		err = renderer.Message(message)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = svc.ListenToEvents(map[string]*components.Channel{channel.ID: &channel}, renderer)
+	if endErr := renderer.End(); endErr != nil && err == nil {
+		err = endErr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quietRenderer calls done when no message was rendered for a while, since
+// the renderer started.
+type quietRenderer struct {
+	render.Renderer
+	timer *time.Timer
+	quiet time.Duration
+	done  func()
+}
+
+func newQuietRenderer(r render.Renderer, quiet time.Duration, done func()) *quietRenderer {
+	return &quietRenderer{Renderer: r, quiet: quiet, done: done}
+}
+
+func (r *quietRenderer) Start() error {
+	r.timer = time.AfterFunc(r.quiet, r.done)
+	return r.Renderer.Start()
+}
+
+func (r *quietRenderer) Message(message components.Message) error {
+	if r.timer != nil {
+		r.timer.Reset(r.quiet)
+	}
+	return r.Renderer.Message(message)
+}
+
+func (r *quietRenderer) End() error {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	return r.Renderer.End()
+}
@@ -0,0 +1,40 @@
+package tui
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+	"golang.org/x/sys/unix"
+)
+
+// terminal puts the terminal in raw mode on the alternate screen and
+// restores it afterward.
+type terminal struct {
+	fd    int
+	state *readline.State
+}
+
+func openTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	// Alternate screen, hidden cursor
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	return &terminal{fd: fd, state: state}, nil
+}
+
+func (t *terminal) close() error {
+	os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+	return readline.Restore(t.fd, t.state)
+}
+
+// size returns the width and height of the terminal.
+func (t *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
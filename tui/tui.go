@@ -0,0 +1,511 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+const (
+	sidebarWidth = 24
+	maxMessages  = 1000
+
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleFaint   = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+	styleYellow  = "\x1b[1;33m"
+)
+
+// SendFunc posts the text typed in the input line to the channel.
+type SendFunc func(channel components.Channel, text string) error
+
+// UI is an interactive interface with a channel sidebar, a scrollable
+// message pane and an input line. It implements render.Renderer so it is fed
+// by the same stream as the other outputs.
+//
+// Keys:
+//
+//	Up/Down, Ctrl-P/Ctrl-N   select the previous/next channel
+//	PgUp/PgDn                scroll the messages
+//	Enter                    send the input line to the selected channel
+//	Ctrl-U                   clear the input line
+//	Ctrl-C                   quit
+type UI struct {
+	channels []components.Channel
+	messages map[string][]components.Message
+	unread   map[string]int
+	selected int
+	scroll   int
+	input    []rune
+	status   string
+	send     SendFunc
+	options  render.Options
+	term     *terminal
+	mutex    sync.Mutex
+}
+
+// New creates the interface for the channels, in the order they are listed
+// in the sidebar.
+func New(channels []components.Channel, send SendFunc, options render.Options) *UI {
+	return &UI{
+		channels: channels,
+		messages: make(map[string][]components.Message),
+		unread:   make(map[string]int),
+		send:     send,
+		options:  options,
+	}
+}
+
+func key(channel *components.Channel) string {
+	return channel.Workspace + "/" + channel.ID
+}
+
+func (ui *UI) Start() error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	ui.term = term
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	go func() {
+		for range resize {
+			ui.mutex.Lock()
+			ui.draw()
+			ui.mutex.Unlock()
+		}
+	}()
+
+	ui.draw()
+	return nil
+}
+
+func (ui *UI) Message(message components.Message) error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	k := ui.append(message)
+	if len(ui.channels) == 0 || k != key(&ui.channels[ui.selected]) {
+		ui.unread[k]++
+	}
+	if ui.options.Bell && message.Live && ui.options.IsAlert(message) {
+		os.Stdout.WriteString("\a")
+	}
+	ui.draw()
+	return nil
+}
+
+// Gap shows a marker in the channel before the messages missed while
+// disconnected.
+func (ui *UI) Gap(gap components.Gap) error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	ui.append(components.Message{
+		Channel: gap.Channel,
+		Time:    gap.Until,
+		Name:    "slag",
+		Content: "--- " + gap.String() + " ---",
+	})
+	ui.draw()
+	return nil
+}
+
+// append adds the message to its channel and returns the channel key. The
+// mutex must be held.
+func (ui *UI) append(message components.Message) string {
+	k := key(message.Channel)
+	messages := append(ui.messages[k], message)
+	if len(messages) > maxMessages {
+		messages = messages[len(messages)-maxMessages:]
+	}
+	ui.messages[k] = messages
+	return k
+}
+
+// Edit shows the original content before the new one when known, e.g.
+// 'old → new (edited)'.
+func (ui *UI) Edit(edit components.Edit) error {
+	message := render.Edited(edit, func(original string) string {
+		return original
+	})
+	return ui.replace(message, message.Content+" (edited)")
+}
+
+// Delete keeps the deleted message as a tombstone, with its content when
+// known.
+func (ui *UI) Delete(deletion components.Deletion) error {
+	message := deletion.Message()
+	return ui.replace(message, strings.TrimSpace("(deleted) "+message.Content))
+}
+
+// replace updates the content of a message already displayed.
+func (ui *UI) replace(message components.Message, content string) error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	messages := ui.messages[key(message.Channel)]
+	for i := range messages {
+		if messages[i].Timestamp == message.Timestamp {
+			messages[i].Content = content
+			messages[i].Attachments = message.Attachments
+			ui.draw()
+			return nil
+		}
+	}
+	return nil
+}
+
+// Reaction updates the reactions of the message, when displayed.
+func (ui *UI) Reaction(reaction components.Reaction) error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	messages := ui.messages[key(reaction.Channel)]
+	for i := range messages {
+		if messages[i].Timestamp == reaction.Timestamp {
+			if reaction.Message != nil {
+				messages[i].Reactions = reaction.Message.Reactions
+			} else {
+				messages[i].Reactions = messages[i].Reactions.Update(reaction.EmojiName, reaction.Emoji, reaction.Removed)
+			}
+			ui.draw()
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ui *UI) End() error {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	if ui.term == nil {
+		return nil
+	}
+	err := ui.term.close()
+	ui.term = nil
+	return err
+}
+
+// Write displays the last line written in the status bar, so that the logs
+// don't garble the screen.
+func (ui *UI) Write(p []byte) (int, error) {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	lines := strings.Split(strings.TrimSpace(string(p)), "\n")
+	ui.status = lines[len(lines)-1]
+	ui.draw()
+	return len(p), nil
+}
+
+// Run handles the keyboard input until Ctrl-C is pressed.
+func (ui *UI) Run() error {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return err
+		}
+		ui.mutex.Lock()
+		quit := ui.handleKey(r, reader)
+		ui.draw()
+		ui.mutex.Unlock()
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (ui *UI) handleKey(r rune, reader *bufio.Reader) bool {
+	switch r {
+	case 3: // Ctrl-C
+		return true
+	case 14: // Ctrl-N
+		ui.selectChannel(ui.selected + 1)
+	case 16: // Ctrl-P
+		ui.selectChannel(ui.selected - 1)
+	case 21: // Ctrl-U
+		ui.input = ui.input[:0]
+	case 127, 8: // Backspace
+		if len(ui.input) > 0 {
+			ui.input = ui.input[:len(ui.input)-1]
+		}
+	case '\r', '\n':
+		ui.submit()
+	case 27: // Escape sequence
+		switch readEscape(reader) {
+		case "[A":
+			ui.selectChannel(ui.selected - 1)
+		case "[B":
+			ui.selectChannel(ui.selected + 1)
+		case "[5~":
+			ui.scroll += ui.paneHeight() / 2
+		case "[6~":
+			ui.scroll -= ui.paneHeight() / 2
+			if ui.scroll < 0 {
+				ui.scroll = 0
+			}
+		}
+	default:
+		if r >= 32 {
+			ui.input = append(ui.input, r)
+		}
+	}
+	return false
+}
+
+func readEscape(reader *bufio.Reader) string {
+	var seq []rune
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		if len(seq) > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			return string(seq)
+		}
+		if len(seq) == 1 && r != '[' && r != 'O' {
+			return string(seq)
+		}
+	}
+}
+
+func (ui *UI) selectChannel(i int) {
+	if len(ui.channels) == 0 {
+		return
+	}
+	ui.selected = (i + len(ui.channels)) % len(ui.channels)
+	ui.scroll = 0
+	delete(ui.unread, key(&ui.channels[ui.selected]))
+}
+
+func (ui *UI) submit() {
+	text := strings.TrimSpace(string(ui.input))
+	if text == "" || len(ui.channels) == 0 {
+		return
+	}
+	channel := ui.channels[ui.selected]
+	ui.input = ui.input[:0]
+	// Sending happens outside of the lock, the message is displayed when it
+	// comes back through the event stream.
+	go func() {
+		err := ui.send(channel, text)
+		if err != nil {
+			ui.Write([]byte(fmt.Sprintf("Failed to send the message: %s", err)))
+		}
+	}()
+}
+
+func (ui *UI) paneHeight() int {
+	if ui.term == nil {
+		return 0
+	}
+	_, h := ui.term.size()
+	return h - 2
+}
+
+// draw redraws the whole screen. The mutex must be held.
+func (ui *UI) draw() {
+	if ui.term == nil {
+		return
+	}
+	width, height := ui.term.size()
+	paneHeight := height - 2
+	paneWidth := width - sidebarWidth - 1
+
+	sidebar := ui.sidebarLines()
+	pane := ui.paneLines(paneWidth)
+
+	// Keep the selected channel visible in the sidebar
+	sidebarOffset := 0
+	for i, line := range sidebar {
+		if line.selected && i >= paneHeight {
+			sidebarOffset = i - paneHeight + 1
+		}
+	}
+	paneOffset := len(pane) - paneHeight - ui.scroll
+	if paneOffset < 0 {
+		ui.scroll += paneOffset
+		if ui.scroll < 0 {
+			ui.scroll = 0
+		}
+		paneOffset = 0
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H")
+	for row := 0; row < paneHeight; row++ {
+		fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2K", row+1)
+		if i := row + sidebarOffset; i < len(sidebar) {
+			b.WriteString(sidebar[i].render(sidebarWidth))
+		} else {
+			b.WriteString(strings.Repeat(" ", sidebarWidth))
+		}
+		b.WriteString(styleFaint + "│" + styleReset)
+		if i := row + paneOffset; i < len(pane) {
+			b.WriteString(pane[i].render(paneWidth))
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2K", height-1)
+	b.WriteString(line{text: ui.statusText(), style: styleReverse}.render(width))
+
+	prompt := "> " + string(ui.input)
+	if n := utf8.RuneCountInString(prompt); n >= width {
+		prompt = string([]rune(prompt)[n-width+1:])
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2K%s\x1b[?25h", height, prompt)
+	os.Stdout.WriteString(b.String())
+}
+
+func (ui *UI) statusText() string {
+	if len(ui.channels) == 0 {
+		return " " + ui.status
+	}
+	channel := ui.channels[ui.selected]
+	text := " " + ui.label(&channel)
+	if channel.Topic != "" {
+		text += " — " + channel.Topic
+	}
+	if ui.scroll > 0 {
+		text += fmt.Sprintf(" [+%d]", ui.scroll)
+	}
+	if ui.status != "" {
+		text += " | " + ui.status
+	}
+	return text
+}
+
+func (ui *UI) label(channel *components.Channel) string {
+	prefix := "#"
+	if channel.Type == components.ChannelTypeIM {
+		prefix = "@"
+	}
+	if ui.options.ShowWorkspace {
+		return channel.Workspace + "/" + prefix + channel.Name
+	}
+	return prefix + channel.Name
+}
+
+var sectionTitles = map[string]string{
+	components.ChannelTypeChannel: "Channels",
+	components.ChannelTypeGroup:   "Groups",
+	components.ChannelTypeMpIM:    "Group messages",
+	components.ChannelTypeIM:      "Direct messages",
+}
+
+func (ui *UI) sidebarLines() []line {
+	lines := make([]line, 0, len(ui.channels))
+	section := ""
+	for i := range ui.channels {
+		channel := &ui.channels[i]
+		if channel.Type != section {
+			section = channel.Type
+			lines = append(lines, line{text: sectionTitles[section], style: styleBold})
+		}
+		text := " " + ui.label(channel)
+		if channel.Type == components.ChannelTypeIM {
+			presence := "○"
+			if channel.Presence == "active" {
+				presence = "●"
+			}
+			text = " " + presence + text
+		}
+		style := ui.options.ChannelStyle(channel)
+		if count := ui.unread[key(channel)]; count > 0 {
+			text = fmt.Sprintf("%s (%d)", text, count)
+			style = styleBold + style
+		}
+		if i == ui.selected {
+			style = styleReverse
+		}
+		lines = append(lines, line{text: text, style: style, selected: i == ui.selected})
+	}
+	return lines
+}
+
+func (ui *UI) paneLines(width int) []line {
+	if len(ui.channels) == 0 || width <= 4 {
+		return nil
+	}
+	lines := make([]line, 0)
+	for _, message := range ui.messages[key(&ui.channels[ui.selected])] {
+		header := fmt.Sprintf("%s @%s", message.Time.Format("15:04"), message.Name)
+		if message.IsReply {
+			header += " ≡"
+		}
+		style := ""
+		if ui.options.IsAlert(message) {
+			if ui.options.Mark != "" {
+				header = ui.options.Mark + " " + header
+			}
+			style = styleYellow
+		}
+		lines = append(lines, line{text: header, style: ui.options.UserStyle(message)})
+		for _, text := range wrap(message.Content, width-2) {
+			lines = append(lines, line{text: "  " + text, style: style})
+		}
+		for _, attachment := range message.Attachments {
+			for _, text := range wrap(attachment.Content, width-4) {
+				lines = append(lines, line{text: "  | " + text, style: styleFaint})
+			}
+		}
+		if len(message.Reactions) > 0 {
+			lines = append(lines, line{text: "  " + message.Reactions.String(), style: styleFaint})
+		}
+	}
+	return lines
+}
+
+type line struct {
+	text     string
+	style    string
+	selected bool
+}
+
+// render pads or truncates the line to the width and applies its style.
+func (l line) render(width int) string {
+	runes := []rune(l.text)
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+	text := string(runes) + strings.Repeat(" ", width-len(runes))
+	if l.style == "" {
+		return text
+	}
+	return l.style + text + styleReset
+}
+
+// wrap splits the text in lines no longer than width runes, breaking on
+// spaces when possible.
+func wrap(text string, width int) []string {
+	var lines []string
+	if text == "" {
+		return lines
+	}
+	for _, paragraph := range strings.Split(text, "\n") {
+		runes := []rune(paragraph)
+		for len(runes) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if runes[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			lines = append(lines, string(runes[:cut]))
+			runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
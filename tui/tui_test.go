@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/j-martin/slag/components"
+	"github.com/j-martin/slag/render"
+)
+
+func TestWrap(t *testing.T) {
+	lines := wrap("the quick brown fox\njumps", 10)
+	expected := []string{"the quick", "brown fox", "jumps"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("%q not equal to %q", lines, expected)
+	}
+	lines = wrap("abcdefghijklmnop", 10)
+	expected = []string{"abcdefghij", "klmnop"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("%q not equal to %q", lines, expected)
+	}
+	if lines := wrap("", 10); len(lines) != 0 {
+		t.Errorf("%q is not empty", lines)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	channel := components.Channel{ID: "C1", Workspace: "acme", Name: "general"}
+	ui := New([]components.Channel{channel}, nil, render.Options{})
+	original := components.Message{Channel: &channel, Timestamp: "1.0", Content: "helo"}
+	ui.Message(original)
+	edited := original
+	edited.Content = "hello"
+	ui.Edit(components.Edit{Message: edited, Original: &original})
+	if content := ui.messages[key(&channel)][0].Content; content != "helo → hello (edited)" {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestColors(t *testing.T) {
+	channel := components.Channel{ID: "C1", Workspace: "acme", Name: "general", Type: components.ChannelTypeChannel}
+	other := components.Channel{ID: "C2", Workspace: "acme", Name: "random", Type: components.ChannelTypeChannel}
+	red, _ := render.ParseColor("red")
+	green, _ := render.ParseColor("green")
+	options := render.Options{
+		UserColors:    map[string]render.Color{"bob": red},
+		ChannelColors: map[string]render.Color{"random": green},
+	}
+	ui := New([]components.Channel{channel, other}, nil, options)
+	ui.Message(components.Message{Channel: &channel, Timestamp: "1.0", Name: "bob", Content: "hello"})
+	if header := ui.paneLines(40)[0]; header.style != "\x1b[31m" {
+		t.Errorf("unexpected header style: %q", header.style)
+	}
+	if sidebar := ui.sidebarLines()[2]; sidebar.style != "\x1b[32m" {
+		t.Errorf("unexpected sidebar style: %q", sidebar.style)
+	}
+}